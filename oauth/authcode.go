@@ -0,0 +1,72 @@
+// Copyright 2014 The goauth2 Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package oauth
+
+// AuthCodeRequest builds an authorization URL step by step. Create one
+// with Config.AuthCode:
+//
+//	url := config.AuthCode().State(state).Scopes("email", "profile").Offline().URL()
+//
+// It is equivalent to calling AuthCodeURL with the corresponding Config
+// settings and params.
+type AuthCodeRequest struct {
+	config Config
+	state  string
+	params []AuthParam
+}
+
+// AuthCode returns an AuthCodeRequest for c. Changes made through it do
+// not affect c.
+func (c *Config) AuthCode() *AuthCodeRequest {
+	return &AuthCodeRequest{config: *c}
+}
+
+// State sets the state parameter.
+func (r *AuthCodeRequest) State(state string) *AuthCodeRequest {
+	r.state = state
+	return r
+}
+
+// Scopes adds scopes to those of the Config.
+func (r *AuthCodeRequest) Scopes(scopes ...string) *AuthCodeRequest {
+	r.config = *r.config.WithScopes(scopes...)
+	return r
+}
+
+// RedirectURL sets the redirect URL, in place of the Config's.
+func (r *AuthCodeRequest) RedirectURL(u string) *AuthCodeRequest {
+	r.config.RedirectURL = u
+	return r
+}
+
+// Offline asks for a refresh token, as described for Config.Offline.
+func (r *AuthCodeRequest) Offline() *AuthCodeRequest {
+	r.config.Offline = true
+	return r
+}
+
+// Param adds the parameter key with the given value.
+func (r *AuthCodeRequest) Param(key, value string) *AuthCodeRequest {
+	r.params = append(r.params, AuthParam{key, value})
+	return r
+}
+
+// Params adds the given parameters, such as those returned by Prompt and
+// LoginHint.
+func (r *AuthCodeRequest) Params(params ...AuthParam) *AuthCodeRequest {
+	r.params = append(r.params, params...)
+	return r
+}
+
+// URL returns the authorization URL, as returned by Config.AuthCodeURL.
+func (r *AuthCodeRequest) URL() string {
+	return r.config.AuthCodeURL(r.state, r.params...)
+}
+
+// BuildURL returns the authorization URL, as returned by
+// Config.BuildAuthCodeURL.
+func (r *AuthCodeRequest) BuildURL() (string, error) {
+	return r.config.BuildAuthCodeURL(r.state, r.params...)
+}
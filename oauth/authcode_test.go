@@ -0,0 +1,35 @@
+// Copyright 2014 The goauth2 Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package oauth
+
+import "testing"
+
+func TestAuthCodeRequest(t *testing.T) {
+	config := &Config{
+		ClientId:    "cl13nt1d",
+		AuthURL:     "https://example.com/auth",
+		RedirectURL: "https://example.com/callback",
+		Scope:       "a",
+	}
+	got := config.AuthCode().
+		State("st4t3").
+		Scopes("b", "c").
+		Offline().
+		Param("hd", "example.com").
+		Params(Prompt("consent")).
+		URL()
+
+	want := config.WithScopes("b", "c")
+	want.Offline = true
+	if w := want.AuthCodeURL("st4t3", AuthParam{"hd", "example.com"}, Prompt("consent")); got != w {
+		t.Errorf("URL() = %q, want %q", got, w)
+	}
+	if config.Scope != "a" || config.Offline {
+		t.Errorf("AuthCode changed the Config: %+v", config)
+	}
+	if _, err := config.AuthCode().Params(Prompt("bogus")).BuildURL(); err == nil {
+		t.Errorf("BuildURL with invalid prompt succeeded, want error")
+	}
+}
@@ -0,0 +1,63 @@
+// Copyright 2014 The goauth2 Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package oauth
+
+import (
+	"math/rand"
+	"time"
+)
+
+// A Backoff chooses how long a Transport waits before retrying a failed
+// request to the token endpoint. NextDelay is called with the number of
+// retries already made, starting at zero for the first retry. It may be
+// called concurrently for different requests.
+type Backoff interface {
+	NextDelay(attempt int) time.Duration
+}
+
+// FixedBackoff waits the same time before every retry.
+type FixedBackoff time.Duration
+
+// NextDelay returns b.
+func (b FixedBackoff) NextDelay(attempt int) time.Duration {
+	return time.Duration(b)
+}
+
+// ExponentialBackoff waits Initial before the first retry and twice as
+// long before each later one, up to Max if it is positive. With Jitter,
+// each delay is chosen at random between half and all of that, so that
+// clients which failed together do not retry together.
+type ExponentialBackoff struct {
+	Initial time.Duration
+	Max     time.Duration
+	Jitter  bool
+}
+
+// NextDelay returns the delay before retry number attempt.
+func (b ExponentialBackoff) NextDelay(attempt int) time.Duration {
+	d := b.Initial
+	for i := 0; i < attempt && d < 1<<40; i++ {
+		d *= 2
+	}
+	if b.Max > 0 && d > b.Max {
+		d = b.Max
+	}
+	if b.Jitter && d > 0 {
+		d = d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+	}
+	return d
+}
+
+// backoff returns the Backoff to use for retries of token requests.
+func (t *Transport) backoff() Backoff {
+	if t.Backoff != nil {
+		return t.Backoff
+	}
+	delay := t.RetryBackoff
+	if delay <= 0 {
+		delay = 500 * time.Millisecond
+	}
+	return ExponentialBackoff{Initial: delay, Jitter: true}
+}
@@ -0,0 +1,154 @@
+// Copyright 2014 The goauth2 Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package oauth
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		b    Backoff
+		want []time.Duration
+	}{
+		{FixedBackoff(time.Second), []time.Duration{time.Second, time.Second, time.Second, time.Second}},
+		{ExponentialBackoff{Initial: time.Second}, []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second}},
+		{ExponentialBackoff{Initial: time.Second, Max: 3 * time.Second}, []time.Duration{time.Second, 2 * time.Second, 3 * time.Second, 3 * time.Second}},
+	}
+	for _, tt := range tests {
+		var got []time.Duration
+		for attempt := range tt.want {
+			got = append(got, tt.b.NextDelay(attempt))
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%#v: delays %v, want %v", tt.b, got, tt.want)
+		}
+	}
+
+	b := ExponentialBackoff{Initial: time.Second, Jitter: true}
+	for attempt := 0; attempt < 4; attempt++ {
+		max := time.Second << uint(attempt)
+		if d := b.NextDelay(attempt); d < max/2 || d > max {
+			t.Errorf("%#v.NextDelay(%d) = %v, want between %v and %v", b, attempt, d, max/2, max)
+		}
+	}
+	if d := (ExponentialBackoff{Initial: time.Second}).NextDelay(1000); d <= 0 {
+		t.Errorf("NextDelay(1000) = %v, want a positive delay", d)
+	}
+}
+
+func TestTransportBackoff(t *testing.T) {
+	var slept []time.Duration
+	sleep = func(_ context.Context, d time.Duration) error {
+		slept = append(slept, d)
+		return nil
+	}
+	defer func() { sleep = sleepContext }()
+
+	failures := 3
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if failures > 0 {
+			failures--
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"access_token":"token2","expires_in":3600}`)
+	}))
+	defer server.Close()
+
+	transport := &Transport{
+		Config:       &Config{TokenURL: server.URL + "/token"},
+		Token:        &Token{AccessToken: "token1", RefreshToken: "refreshtoken1"},
+		MaxRetries:   3,
+		RetryBackoff: time.Hour,
+		Backoff:      FixedBackoff(time.Second),
+	}
+	if err := transport.Refresh(); err != nil {
+		t.Fatalf("Refresh: %v", err)
+	}
+	if want := []time.Duration{time.Second, time.Second, time.Second}; !reflect.DeepEqual(slept, want) {
+		t.Errorf("slept %v, want %v", slept, want)
+	}
+}
+
+func TestBackoffContext(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	newTransport := func() *Transport {
+		return &Transport{
+			Config:     &Config{TokenURL: server.URL + "/token"},
+			Token:      &Token{AccessToken: "token1", RefreshToken: "refreshtoken1"},
+			MaxRetries: 2,
+			Backoff:    FixedBackoff(time.Hour),
+		}
+	}
+
+	// A long delay ends with the context.
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := newTransport().RefreshContext(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("RefreshContext = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if d := time.Since(start); d > 5*time.Second {
+		t.Errorf("RefreshContext returned after %v, want soon after the context's deadline", d)
+	}
+	if requests != 1 {
+		t.Errorf("%d requests made, want 1", requests)
+	}
+
+	// There is no wait after the final attempt.
+	var slept []time.Duration
+	sleep = func(_ context.Context, d time.Duration) error {
+		slept = append(slept, d)
+		return nil
+	}
+	defer func() { sleep = sleepContext }()
+	requests = 0
+	if err := newTransport().Refresh(); err == nil {
+		t.Errorf("Refresh succeeded, want error")
+	}
+	if requests != 3 || len(slept) != 2 {
+		t.Errorf("made %d requests with waits %v, want 3 requests and 2 waits", requests, slept)
+	}
+}
+
+func TestBackoffLocalError(t *testing.T) {
+	var slept []time.Duration
+	sleep = func(_ context.Context, d time.Duration) error {
+		slept = append(slept, d)
+		return nil
+	}
+	defer func() { sleep = sleepContext }()
+
+	// A request that cannot be sent is not retried.
+	transport := &Transport{
+		Config:     &Config{TokenURL: "http://example.com/token"},
+		Token:      &Token{AccessToken: "token1", RefreshToken: "refreshtoken1"},
+		MaxRetries: 3,
+		Backoff:    FixedBackoff(time.Second),
+	}
+	err := transport.Refresh()
+	if err == nil || !strings.Contains(err.Error(), "must use https") {
+		t.Errorf("Refresh = %v, want an https error", err)
+	}
+	if len(slept) != 0 {
+		t.Errorf("slept %v, want no waits", slept)
+	}
+}
@@ -0,0 +1,77 @@
+// Copyright 2014 The goauth2 Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package oauth
+
+import (
+	"net/http"
+	"strings"
+)
+
+// bearerError returns the error attribute of the Bearer challenge in the
+// WWW-Authenticate header h, as described in RFC 6750 section 3, or ""
+// if there is none.
+//
+//	WWW-Authenticate: Bearer realm="example", error="invalid_token"
+func bearerError(h http.Header) string {
+	for _, v := range h["Www-Authenticate"] {
+		bearer := false
+		for _, item := range splitQuoted(v, ',') {
+			item = strings.TrimSpace(item)
+			// An item that starts with a bare token begins a new
+			// challenge, and may be followed by its first parameter.
+			if i := strings.IndexAny(item, " \t"); i >= 0 && !strings.Contains(item[:i], "=") {
+				bearer = strings.EqualFold(item[:i], "Bearer")
+				item = strings.TrimSpace(item[i+1:])
+			} else if !strings.Contains(item, "=") {
+				bearer = strings.EqualFold(item, "Bearer")
+				continue
+			}
+			if !bearer {
+				continue
+			}
+			i := strings.Index(item, "=")
+			if i < 0 || !strings.EqualFold(strings.TrimSpace(item[:i]), "error") {
+				continue
+			}
+			return unquote(strings.TrimSpace(item[i+1:]))
+		}
+	}
+	return ""
+}
+
+// splitQuoted splits s at each sep that is not within a quoted string.
+func splitQuoted(s string, sep byte) []string {
+	var parts []string
+	quoted, start := false, 0
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '"':
+			quoted = !quoted
+		case c == '\\' && quoted:
+			i++
+		case c == sep && !quoted:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+// unquote removes the quotes and escapes from the quoted string s.
+// Unquoted values are returned unchanged.
+func unquote(s string) string {
+	if len(s) < 2 || s[0] != '"' || s[len(s)-1] != '"' {
+		return s
+	}
+	s = s[1 : len(s)-1]
+	var b []byte
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			i++
+		}
+		b = append(b, s[i])
+	}
+	return string(b)
+}
@@ -0,0 +1,37 @@
+// Copyright 2014 The goauth2 Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package oauth
+
+import (
+	"net/http"
+	"testing"
+)
+
+var bearerErrorTests = []struct {
+	header []string
+	want   string
+}{
+	{nil, ""},
+	{[]string{""}, ""},
+	{[]string{"Bearer"}, ""},
+	{[]string{`Bearer realm="example"`}, ""},
+	{[]string{`Bearer error="invalid_token"`}, "invalid_token"},
+	{[]string{`Bearer realm="example", error="invalid_token", error_description="The access token expired"`}, "invalid_token"},
+	{[]string{`bearer ERROR=insufficient_scope, scope="read write"`}, "insufficient_scope"},
+	{[]string{`Bearer realm="a, error=\"x\"", error="invalid_request"`}, "invalid_request"},
+	{[]string{`Basic realm="example", error="invalid_token"`}, ""},
+	{[]string{`Basic realm="example", Bearer error="invalid_token"`}, "invalid_token"},
+	{[]string{`Basic abc==`, `Bearer error="invalid_token"`}, "invalid_token"},
+	{[]string{`garbage "unterminated`}, ""},
+}
+
+func TestBearerError(t *testing.T) {
+	for _, tt := range bearerErrorTests {
+		h := http.Header{"Www-Authenticate": tt.header}
+		if g := bearerError(h); g != tt.want {
+			t.Errorf("bearerError(%q) = %q, want %q", tt.header, g, tt.want)
+		}
+	}
+}
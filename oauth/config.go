@@ -0,0 +1,81 @@
+// Copyright 2014 The goauth2 Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package oauth
+
+import (
+	"encoding/json"
+	"io/ioutil"
+)
+
+// configFile is the format of a client configuration file. Both the
+// "uri" spellings used by Google and the "url" spellings of Config are
+// accepted.
+type configFile struct {
+	ClientId     string   `json:"client_id"`
+	ClientSecret string   `json:"client_secret"`
+	AuthURI      string   `json:"auth_uri"`
+	AuthURL      string   `json:"auth_url"`
+	TokenURI     string   `json:"token_uri"`
+	TokenURL     string   `json:"token_url"`
+	RedirectURIs []string `json:"redirect_uris"`
+	RedirectURL  string   `json:"redirect_url"`
+}
+
+// ConfigFromJSON reads a Config from the JSON file at path. The file may
+// be a client secrets file downloaded from the Google API Console, in
+// which the settings are wrapped in a "web" or "installed" object, or a
+// flat object with the same fields:
+//
+//	{
+//		"client_id": "...",
+//		"client_secret": "...",
+//		"auth_uri": "https://provider.example.com/auth",
+//		"token_uri": "https://provider.example.com/token",
+//		"redirect_uris": ["https://example.com/oauth2callback"]
+//	}
+//
+// The names "auth_url", "token_url" and "redirect_url" may be used instead.
+// If there are several redirect URIs, the first is used.
+func ConfigFromJSON(path string) (*Config, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var f struct {
+		Web       *configFile `json:"web"`
+		Installed *configFile `json:"installed"`
+		configFile
+	}
+	if err := json.Unmarshal(b, &f); err != nil {
+		return nil, OAuthError{"ConfigFromJSON", path + ": " + err.Error()}
+	}
+	cf := &f.configFile
+	switch {
+	case f.Web != nil:
+		cf = f.Web
+	case f.Installed != nil:
+		cf = f.Installed
+	}
+	if cf.ClientId == "" {
+		return nil, OAuthError{"ConfigFromJSON", path + ": no client_id"}
+	}
+	c := &Config{
+		ClientId:     cf.ClientId,
+		ClientSecret: cf.ClientSecret,
+		AuthURL:      cf.AuthURL,
+		TokenURL:     cf.TokenURL,
+		RedirectURL:  cf.RedirectURL,
+	}
+	if c.AuthURL == "" {
+		c.AuthURL = cf.AuthURI
+	}
+	if c.TokenURL == "" {
+		c.TokenURL = cf.TokenURI
+	}
+	if c.RedirectURL == "" && len(cf.RedirectURIs) > 0 {
+		c.RedirectURL = cf.RedirectURIs[0]
+	}
+	return c, nil
+}
@@ -0,0 +1,71 @@
+// Copyright 2014 The goauth2 Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package oauth
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestConfigFromJSON(t *testing.T) {
+	google := Config{
+		ClientId:     "cl13nt1d.apps.googleusercontent.com",
+		ClientSecret: "s3cr3t",
+		AuthURL:      "https://accounts.google.com/o/oauth2/auth",
+		TokenURL:     "https://accounts.google.com/o/oauth2/token",
+	}
+	web := google
+	web.RedirectURL = "https://example.com/oauth2callback"
+	installed := google
+	installed.RedirectURL = "urn:ietf:wg:oauth:2.0:oob"
+	tests := []struct {
+		file string
+		want Config
+	}{
+		{"client_secret_web.json", web},
+		{"client_secret_installed.json", installed},
+		{"client_config.json", Config{
+			ClientId:     "cl13nt1d",
+			ClientSecret: "s3cr3t",
+			AuthURL:      "https://provider.example.com/auth",
+			TokenURL:     "https://provider.example.com/token",
+			RedirectURL:  "https://example.com/oauth2callback",
+		}},
+	}
+	for _, tt := range tests {
+		c, err := ConfigFromJSON(filepath.Join("testdata", tt.file))
+		if err != nil {
+			t.Errorf("%s: ConfigFromJSON: %v", tt.file, err)
+			continue
+		}
+		if !reflect.DeepEqual(*c, tt.want) {
+			t.Errorf("%s: ConfigFromJSON = %+v, want %+v", tt.file, *c, tt.want)
+		}
+	}
+}
+
+func TestConfigFromJSONError(t *testing.T) {
+	dir, err := ioutil.TempDir("", "oauth-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, body := range []string{"", "not json", `{"web":{"client_secret":"s3cr3t"}}`} {
+		path := filepath.Join(dir, "config.json")
+		if err := ioutil.WriteFile(path, []byte(body), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if c, err := ConfigFromJSON(path); err == nil {
+			t.Errorf("ConfigFromJSON(%q) = %+v, want error", body, c)
+		}
+	}
+	if _, err := ConfigFromJSON(filepath.Join(dir, "missing.json")); err == nil {
+		t.Errorf("ConfigFromJSON of missing file succeeded, want error")
+	}
+}
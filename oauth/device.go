@@ -0,0 +1,145 @@
+// Copyright 2014 The goauth2 Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package oauth
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/url"
+	"time"
+)
+
+// DeviceCode is the response of the device authorization endpoint,
+// as described in RFC 8628 section 3.2. The UserCode and
+// VerificationURI should be shown to the user, who completes the
+// authorization on another device.
+type DeviceCode struct {
+	DeviceCode              string
+	UserCode                string
+	VerificationURI         string
+	VerificationURIComplete string    // Optional; includes the UserCode.
+	Expiry                  time.Time // If zero the code has no (known) expiry time.
+
+	// Interval is the minimum time to wait between polling requests.
+	Interval time.Duration
+}
+
+// defaultDeviceInterval is the polling interval used when the server
+// does not specify one.
+const defaultDeviceInterval = 5 * time.Second
+
+// sleep is sleepContext, replaced during tests.
+var sleep = sleepContext
+
+// sleepContext waits for d to pass or for ctx to be done, whichever
+// comes first, and returns ctx.Err() in the second case.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// DeviceAuth requests a device code from the Config's DeviceAuthURL.
+// Once the user has been shown the code, call PollDeviceToken to
+// obtain the access Token.
+func (t *Transport) DeviceAuth() (*DeviceCode, error) {
+	if t.Config == nil {
+		return nil, OAuthError{"DeviceAuth", "no Config supplied"}
+	}
+	if t.DeviceAuthURL == "" {
+		return nil, OAuthError{"DeviceAuth", "no DeviceAuthURL supplied"}
+	}
+	r, err := t.postForm(context.Background(), t.DeviceAuthURL, url.Values{"scope": condVal(t.scope())})
+	if err != nil {
+		return nil, err
+	}
+	defer r.Body.Close()
+	if r.StatusCode != 200 {
+		return nil, OAuthError{"DeviceAuth", "Unexpected HTTP status " + r.Status}
+	}
+	var b struct {
+		DeviceCode              string `json:"device_code"`
+		UserCode                string `json:"user_code"`
+		VerificationURI         string `json:"verification_uri"`
+		VerificationURL         string `json:"verification_url"` // used by Google
+		VerificationURIComplete string `json:"verification_uri_complete"`
+		ExpiresIn               int64  `json:"expires_in"` // seconds
+		Interval                int64  `json:"interval"`   // seconds
+	}
+	if err := json.NewDecoder(io.LimitReader(r.Body, 1<<20)).Decode(&b); err != nil {
+		return nil, OAuthError{"DeviceAuth", err.Error()}
+	}
+	if b.DeviceCode == "" {
+		return nil, OAuthError{"DeviceAuth", "received empty device code"}
+	}
+	dc := &DeviceCode{
+		DeviceCode:              b.DeviceCode,
+		UserCode:                b.UserCode,
+		VerificationURI:         b.VerificationURI,
+		VerificationURIComplete: b.VerificationURIComplete,
+		Interval:                time.Duration(b.Interval) * time.Second,
+	}
+	if dc.VerificationURI == "" {
+		dc.VerificationURI = b.VerificationURL
+	}
+	if b.ExpiresIn != 0 {
+		dc.Expiry = t.now().Add(time.Duration(b.ExpiresIn) * time.Second)
+	}
+	return dc, nil
+}
+
+// PollDeviceToken polls the token endpoint until the user has authorized
+// the device code, then stores the resulting Token on the Transport.
+// It waits dc.Interval between requests, backing off when the server
+// asks it to slow down, and fails once the device code has expired or
+// the user has denied access.
+func (t *Transport) PollDeviceToken(dc *DeviceCode) (*Token, error) {
+	return t.PollDeviceTokenContext(context.Background(), dc)
+}
+
+// PollDeviceTokenContext is like PollDeviceToken, but the requests to the
+// token endpoint are made with the given context, and polling stops with
+// the context's error once it is done, as when the user abandons the
+// login.
+func (t *Transport) PollDeviceTokenContext(ctx context.Context, dc *DeviceCode) (*Token, error) {
+	if t.Config == nil {
+		return nil, OAuthError{"PollDeviceToken", "no Config supplied"}
+	}
+	interval := dc.Interval
+	if interval <= 0 {
+		interval = defaultDeviceInterval
+	}
+	for {
+		if !dc.Expiry.IsZero() && t.now().Add(interval).After(dc.Expiry) {
+			return nil, OAuthError{"PollDeviceToken", "device code expired"}
+		}
+		if err := sleep(ctx, interval); err != nil {
+			return nil, err
+		}
+
+		tok := new(Token)
+		err := t.updateToken(ctx, tok, url.Values{
+			"grant_type":  {"urn:ietf:params:oauth:grant-type:device_code"},
+			"device_code": {dc.DeviceCode},
+		})
+		if err == nil {
+			return tok, t.setToken(tok)
+		}
+		te, ok := err.(*TokenError)
+		switch {
+		case ok && te.Code == "authorization_pending":
+		case ok && te.Code == "slow_down":
+			interval += 5 * time.Second
+		default:
+			return nil, err
+		}
+	}
+}
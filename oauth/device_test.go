@@ -0,0 +1,140 @@
+// Copyright 2014 The goauth2 Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package oauth
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDeviceFlow(t *testing.T) {
+	polls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/device":
+			if g, w := r.FormValue("scope"), "https://example.net/scope"; g != w {
+				t.Errorf("scope = %q, want %q", g, w)
+			}
+			io.WriteString(w, `{
+				"device_code":"d3v1c3",
+				"user_code":"US3R-C0D3",
+				"verification_uri":"https://example.net/device",
+				"expires_in":1800,
+				"interval":2
+			}`)
+		case "/token":
+			if g, w := r.FormValue("grant_type"), "urn:ietf:params:oauth:grant-type:device_code"; g != w {
+				t.Errorf("grant_type = %q, want %q", g, w)
+			}
+			if g, w := r.FormValue("device_code"), "d3v1c3"; g != w {
+				t.Errorf("device_code = %q, want %q", g, w)
+			}
+			polls++
+			switch polls {
+			case 1:
+				w.WriteHeader(http.StatusBadRequest)
+				io.WriteString(w, `{"error":"authorization_pending"}`)
+			case 2:
+				w.WriteHeader(http.StatusBadRequest)
+				io.WriteString(w, `{"error":"slow_down"}`)
+			default:
+				io.WriteString(w, `{"access_token":"token1","refresh_token":"refreshtoken1","expires_in":3600}`)
+			}
+		}
+	}))
+	defer server.Close()
+
+	var slept []time.Duration
+	sleep = func(_ context.Context, d time.Duration) error {
+		slept = append(slept, d)
+		return nil
+	}
+	defer func() { sleep = sleepContext }()
+
+	transport := &Transport{Config: &Config{
+		ClientId:      "cl13nt1d",
+		Scope:         "https://example.net/scope",
+		TokenURL:      server.URL + "/token",
+		DeviceAuthURL: server.URL + "/device",
+	}}
+	dc, err := transport.DeviceAuth()
+	if err != nil {
+		t.Fatalf("DeviceAuth: %v", err)
+	}
+	if g, w := dc.UserCode, "US3R-C0D3"; g != w {
+		t.Errorf("UserCode = %q, want %q", g, w)
+	}
+	if g, w := dc.VerificationURI, "https://example.net/device"; g != w {
+		t.Errorf("VerificationURI = %q, want %q", g, w)
+	}
+	tok, err := transport.PollDeviceToken(dc)
+	if err != nil {
+		t.Fatalf("PollDeviceToken: %v", err)
+	}
+	if tok != transport.Token {
+		t.Errorf("PollDeviceToken did not store the Token on the Transport")
+	}
+	checkToken(t, tok, "token1", "refreshtoken1", "")
+	want := []time.Duration{2 * time.Second, 2 * time.Second, 7 * time.Second}
+	if !reflect.DeepEqual(slept, want) {
+		t.Errorf("polling intervals = %v, want %v", slept, want)
+	}
+}
+
+func TestDeviceFlowDenied(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		io.WriteString(w, `{"error":"access_denied"}`)
+	}))
+	defer server.Close()
+
+	sleep = func(context.Context, time.Duration) error { return nil }
+	defer func() { sleep = sleepContext }()
+
+	transport := &Transport{Config: &Config{TokenURL: server.URL + "/token"}}
+	if _, err := transport.PollDeviceToken(&DeviceCode{DeviceCode: "d3v1c3"}); err == nil {
+		t.Fatal("PollDeviceToken: got nil error, want access_denied")
+	}
+	if transport.Token != nil {
+		t.Errorf("Transport.Token = %+v, want nil", transport.Token)
+	}
+}
+
+func TestPollDeviceTokenContext(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		io.WriteString(w, `{"error":"authorization_pending"}`)
+	}))
+	defer server.Close()
+
+	transport := &Transport{Config: &Config{TokenURL: server.URL + "/token"}}
+	dc := &DeviceCode{DeviceCode: "d3v1c3", Interval: time.Hour}
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := transport.PollDeviceTokenContext(ctx, dc); err != context.DeadlineExceeded {
+		t.Errorf("PollDeviceTokenContext = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if d := time.Since(start); d > 5*time.Second {
+		t.Errorf("PollDeviceTokenContext returned after %v, want soon after the context's deadline", d)
+	}
+}
+
+func TestDeviceAuthNoURL(t *testing.T) {
+	transport := &Transport{Config: &Config{TokenURL: "https://example.net/token"}}
+	if _, err := transport.DeviceAuth(); err == nil {
+		t.Errorf("DeviceAuth without a DeviceAuthURL succeeded, want error")
+	} else if _, ok := err.(OAuthError); !ok {
+		t.Errorf("DeviceAuth without a DeviceAuthURL: err = %#v, want an OAuthError", err)
+	}
+}
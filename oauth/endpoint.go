@@ -0,0 +1,92 @@
+// Copyright 2014 The goauth2 Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package oauth
+
+// Endpoint holds the URLs and settings of a provider's OAuth 2.0
+// endpoints. Empty fields are not supported by the provider.
+type Endpoint struct {
+	AuthURL       string
+	TokenURL      string
+	DeviceAuthURL string
+	RevokeURL     string
+	AuthStyle     AuthStyle
+}
+
+// NewEndpoint returns an Endpoint with the given authorization and token
+// URLs, or an error if they do not pass Validate. Other fields may be set
+// on the result, and checked by calling Validate again.
+func NewEndpoint(authURL, tokenURL string) (Endpoint, error) {
+	e := Endpoint{AuthURL: authURL, TokenURL: tokenURL}
+	return e, e.Validate()
+}
+
+// Validate checks that e has a TokenURL, that its URLs are absolute, and
+// that they all use the same scheme, to catch endpoints copied wrongly.
+// It cannot require the URLs to have the same host, since some providers
+// serve their endpoints from unrelated domains.
+func (e Endpoint) Validate() error {
+	if e.TokenURL == "" {
+		return OAuthError{"Endpoint", "no TokenURL supplied"}
+	}
+	scheme := ""
+	for _, f := range []struct{ name, url string }{
+		{"AuthURL", e.AuthURL},
+		{"TokenURL", e.TokenURL},
+		{"DeviceAuthURL", e.DeviceAuthURL},
+		{"RevokeURL", e.RevokeURL},
+	} {
+		if f.url == "" {
+			continue
+		}
+		u, err := parseAbsURL("Endpoint", f.name, f.url)
+		if err != nil {
+			return err
+		}
+		if scheme == "" {
+			scheme = u.Scheme
+		} else if u.Scheme != scheme {
+			return OAuthError{"Endpoint", f.name + " uses " + u.Scheme + ", not " + scheme}
+		}
+	}
+	return nil
+}
+
+// Endpoints of well-known providers.
+var (
+	Google = Endpoint{
+		AuthURL:       "https://accounts.google.com/o/oauth2/auth",
+		TokenURL:      "https://accounts.google.com/o/oauth2/token",
+		DeviceAuthURL: "https://accounts.google.com/o/oauth2/device/code",
+		RevokeURL:     "https://accounts.google.com/o/oauth2/revoke",
+		AuthStyle:     AuthStyleInParams,
+	}
+	GitHub = Endpoint{
+		AuthURL:       "https://github.com/login/oauth/authorize",
+		TokenURL:      "https://github.com/login/oauth/access_token",
+		DeviceAuthURL: "https://github.com/login/device/code",
+		AuthStyle:     AuthStyleInParams,
+	}
+	Facebook = Endpoint{
+		AuthURL:   "https://www.facebook.com/dialog/oauth",
+		TokenURL:  "https://graph.facebook.com/oauth/access_token",
+		AuthStyle: AuthStyleInParams,
+	}
+)
+
+// WithEndpoint sets the endpoint URLs and AuthStyle of c from e.
+//
+//	config := &oauth.Config{
+//		ClientId:     "...",
+//		ClientSecret: "...",
+//		Scope:        "user:email",
+//	}
+//	oauth.WithEndpoint(config, oauth.GitHub)
+func WithEndpoint(c *Config, e Endpoint) {
+	c.AuthURL = e.AuthURL
+	c.TokenURL = e.TokenURL
+	c.DeviceAuthURL = e.DeviceAuthURL
+	c.RevokeURL = e.RevokeURL
+	c.AuthStyle = e.AuthStyle
+}
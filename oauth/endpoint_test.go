@@ -0,0 +1,63 @@
+// Copyright 2014 The goauth2 Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package oauth
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestWithEndpoint(t *testing.T) {
+	c := &Config{ClientId: "cl13nt1d", RevokeURL: "https://example.com/revoke"}
+	WithEndpoint(c, GitHub)
+	want := Config{
+		ClientId:      "cl13nt1d",
+		AuthURL:       GitHub.AuthURL,
+		TokenURL:      GitHub.TokenURL,
+		DeviceAuthURL: GitHub.DeviceAuthURL,
+		AuthStyle:     AuthStyleInParams,
+	}
+	if !reflect.DeepEqual(*c, want) {
+		t.Errorf("WithEndpoint(GitHub) = %+v, want %+v", *c, want)
+	}
+	for _, e := range []Endpoint{Google, GitHub, Facebook} {
+		c := &Config{ClientId: "cl13nt1d"}
+		WithEndpoint(c, e)
+		if _, err := NewTransport(c); err != nil {
+			t.Errorf("NewTransport with Endpoint %+v: %v", e, err)
+		}
+	}
+}
+
+func TestEndpointValidate(t *testing.T) {
+	for _, e := range []Endpoint{Google, GitHub, Facebook} {
+		if err := e.Validate(); err != nil {
+			t.Errorf("Validate(%+v) = %v", e, err)
+		}
+	}
+	tests := []struct {
+		e     Endpoint
+		valid bool
+	}{
+		{Endpoint{TokenURL: "https://example.com/token"}, true},
+		{Endpoint{AuthURL: "https://example.com/auth"}, false},
+		{Endpoint{AuthURL: "/auth", TokenURL: "https://example.com/token"}, false},
+		{Endpoint{AuthURL: "https://example.com/auth", TokenURL: "http://example.com/token"}, false},
+		{Endpoint{TokenURL: "https://example.com/token", RevokeURL: "%zz"}, false},
+		{Endpoint{AuthURL: "http://localhost/auth", TokenURL: "http://localhost/token"}, true},
+	}
+	for _, tt := range tests {
+		if err := tt.e.Validate(); (err == nil) != tt.valid {
+			t.Errorf("Validate(%+v) = %v, want valid %v", tt.e, err, tt.valid)
+		}
+	}
+
+	if _, err := NewEndpoint("https://example.com/auth", "https://example.com/token"); err != nil {
+		t.Errorf("NewEndpoint: %v", err)
+	}
+	if _, err := NewEndpoint("https://example.com/auth", "example.com/token"); err == nil {
+		t.Errorf("NewEndpoint with relative TokenURL succeeded")
+	}
+}
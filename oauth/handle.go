@@ -0,0 +1,73 @@
+// Copyright 2014 The goauth2 Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package oauth
+
+import "net/http"
+
+// ErrStateMismatch is returned by Handle and TokenFromFragment when the
+// state of the redirect does not match the expected one, which may
+// indicate a forged request.
+var ErrStateMismatch error = OAuthError{"Handle", "state mismatch"}
+
+// AuthError is an error returned by the provider in the redirect to the
+// client, as described in RFC 6749 section 4.1.2.1. For example, its Code
+// is "access_denied" if the user declined to authorize the client.
+type AuthError struct {
+	Code        string
+	Description string
+	URI         string
+}
+
+func (e *AuthError) Error() string {
+	if e.Description != "" {
+		return "OAuthError: " + e.Code + ": " + e.Description
+	}
+	return "OAuthError: " + e.Code
+}
+
+// ExchangeError is returned by Handle when the exchange of the
+// authorization code fails.
+type ExchangeError struct {
+	Err error
+}
+
+func (e *ExchangeError) Error() string {
+	return "OAuthError: Exchange: " + e.Err.Error()
+}
+
+func (e *ExchangeError) Unwrap() error {
+	return e.Err
+}
+
+// Handle completes the authorization code flow for r, the request made
+// when the provider redirects the user back to the RedirectURL. It checks
+// that the request's state matches wantState, then exchanges the code for
+// a Token, which is stored on the Transport.
+//
+// If the provider reports an error, such as the user denying access, Handle
+// returns an *AuthError. If the state does not match it returns
+// ErrStateMismatch, and if the exchange fails it returns an *ExchangeError.
+func (t *Transport) Handle(r *http.Request, wantState string) (*Token, error) {
+	q := r.URL.Query()
+	if code := q.Get("error"); code != "" {
+		return nil, &AuthError{
+			Code:        code,
+			Description: q.Get("error_description"),
+			URI:         q.Get("error_uri"),
+		}
+	}
+	if !ValidateState(q.Get("state"), wantState) {
+		return nil, ErrStateMismatch
+	}
+	code := q.Get("code")
+	if code == "" {
+		return nil, &AuthError{Code: "invalid_request", Description: "no code in redirect"}
+	}
+	tok, err := t.ExchangeContext(r.Context(), code)
+	if err != nil {
+		return nil, &ExchangeError{err}
+	}
+	return tok, nil
+}
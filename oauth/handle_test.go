@@ -0,0 +1,115 @@
+// Copyright 2014 The goauth2 Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package oauth
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestHandle(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.FormValue("code") != "c0d3" {
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, `{"error":"invalid_grant"}`)
+			return
+		}
+		io.WriteString(w, `{"access_token":"token1"}`)
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		query string
+		check func(error) bool
+	}{
+		{"code=c0d3&state=st4t3", func(err error) bool { return err == nil }},
+		{"code=c0d3&state=forged", func(err error) bool { return err == ErrStateMismatch }},
+		{"code=c0d3", func(err error) bool { return err == ErrStateMismatch }},
+		{"error=access_denied&state=st4t3", func(err error) bool {
+			var e *AuthError
+			return errors.As(err, &e) && e.Code == "access_denied"
+		}},
+		{"state=st4t3", func(err error) bool {
+			var e *AuthError
+			return errors.As(err, &e)
+		}},
+		{"code=bad&state=st4t3", func(err error) bool {
+			var e *ExchangeError
+			var te *TokenError
+			return errors.As(err, &e) && errors.As(err, &te) && te.Code == "invalid_grant"
+		}},
+	}
+	for _, tt := range tests {
+		transport := &Transport{Config: &Config{TokenURL: server.URL + "/token"}}
+		r := httptest.NewRequest("GET", "/callback?"+tt.query, nil)
+		tok, err := transport.Handle(r, "st4t3")
+		if !tt.check(err) {
+			t.Errorf("Handle(%q) = %v, %v", tt.query, tok, err)
+		}
+		if err == nil && tok.AccessToken != "token1" {
+			t.Errorf("Handle(%q): AccessToken = %q, want token1", tt.query, tok.AccessToken)
+		}
+	}
+}
+
+func TestHandleElsewhere(t *testing.T) {
+	var challenge string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.FormValue("code") != "c0d3" || CodeChallenge(r.FormValue("code_verifier")) != challenge {
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, `{"error":"invalid_grant"}`)
+			return
+		}
+		io.WriteString(w, `{"access_token":"token1"}`)
+	}))
+	defer server.Close()
+	config := &Config{
+		ClientId: "cl13nt1d",
+		AuthURL:  server.URL + "/auth",
+		TokenURL: server.URL + "/token",
+	}
+
+	// One instance of the app builds the URL, keeping the state and
+	// verifier in a session store shared with the other instances.
+	session := make(map[string]string)
+	state, err := NewState()
+	if err != nil {
+		t.Fatalf("NewState: %v", err)
+	}
+	verifier, err := NewCodeVerifier()
+	if err != nil {
+		t.Fatalf("NewCodeVerifier: %v", err)
+	}
+	session["state"], session["verifier"] = state, verifier
+	first := &Transport{Config: config, CodeVerifier: verifier}
+	u, err := url.Parse(first.AuthCodeURL(state))
+	if err != nil {
+		t.Fatalf("AuthCodeURL: %v", err)
+	}
+	challenge = u.Query().Get("code_challenge")
+
+	// Another instance handles the redirect with what it finds there.
+	second := &Transport{Config: config, CodeVerifier: session["verifier"]}
+	r := httptest.NewRequest("GET", "/callback?code=c0d3&state="+url.QueryEscape(state), nil)
+	tok, err := second.Handle(r, session["state"])
+	if err != nil {
+		t.Fatalf("Handle: %v", err)
+	}
+	if tok.AccessToken != "token1" {
+		t.Errorf("AccessToken = %q, want token1", tok.AccessToken)
+	}
+
+	// Without the verifier the exchange fails.
+	third := &Transport{Config: config}
+	if _, err := third.Handle(r, session["state"]); err == nil {
+		t.Errorf("Handle without the CodeVerifier succeeded, want error")
+	}
+}
@@ -0,0 +1,109 @@
+// Copyright 2014 The goauth2 Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package oauth
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+)
+
+// Claims holds the claims of an OpenID Connect ID token that identify
+// the user.
+type Claims struct {
+	Sub   string   // subject: the user's unique identifier
+	Email string   // the user's email address, if requested
+	Aud   []string // audience: the client IDs the token is intended for
+	Exp   int64    // expiry in seconds since the Unix epoch
+	Nonce string   // the nonce from the authorization request, if any
+}
+
+// IdTokenClaims decodes the claims of the OpenID Connect ID token that the
+// server returned with the Token, stored in Extra["id_token"].
+//
+// The signature of the ID token is NOT verified. The claims may be relied
+// upon only if the Token was received directly from the provider's token
+// endpoint over HTTPS.
+func (t *Token) IdTokenClaims() (*Claims, error) {
+	idToken := t.Extra["id_token"]
+	if idToken == "" {
+		return nil, errors.New("oauth: token has no id_token")
+	}
+	var b struct {
+		Sub   string          `json:"sub"`
+		Email string          `json:"email"`
+		Aud   json.RawMessage `json:"aud"`
+		Exp   int64           `json:"exp"`
+		Nonce string          `json:"nonce"`
+	}
+	if err := decodeJWT(idToken, &b); err != nil {
+		return nil, err
+	}
+	c := &Claims{Sub: b.Sub, Email: b.Email, Exp: b.Exp, Nonce: b.Nonce}
+	// The audience may be a single string or an array of strings.
+	if len(b.Aud) > 0 {
+		var aud string
+		if err := json.Unmarshal(b.Aud, &aud); err == nil {
+			c.Aud = []string{aud}
+		} else if err := json.Unmarshal(b.Aud, &c.Aud); err != nil {
+			return nil, errors.New("oauth: malformed aud claim in id_token")
+		}
+	}
+	return c, nil
+}
+
+// NewNonce returns a random value for the OpenID Connect nonce parameter,
+// which the provider copies into the ID token to prevent it from being
+// replayed. Store it in the user's session with the state, pass it to
+// AuthCodeURL with Nonce, and check the ID token with ValidateNonce.
+func NewNonce() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// Nonce returns an AuthParam that sends nonce in the authorization
+// request.
+//
+//	url := config.AuthCodeURL(state, oauth.Nonce(nonce))
+func Nonce(nonce string) AuthParam {
+	return AuthParam{"nonce", nonce}
+}
+
+// ValidateNonce checks that the Token's ID token carries the nonce
+// claim want, as sent in the authorization request. The comparison
+// takes constant time. An empty nonce is never valid.
+func (t *Token) ValidateNonce(want string) error {
+	c, err := t.IdTokenClaims()
+	if err != nil {
+		return err
+	}
+	if want == "" || subtle.ConstantTimeCompare([]byte(c.Nonce), []byte(want)) != 1 {
+		return errors.New("oauth: id_token nonce does not match")
+	}
+	return nil
+}
+
+// decodeJWT decodes the payload of the JSON Web Token s into v.
+// It does not verify the token's signature.
+func decodeJWT(s string, v interface{}) error {
+	parts := strings.Split(s, ".")
+	if len(parts) != 3 {
+		return errors.New("oauth: malformed JWT: want 3 parts")
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return errors.New("oauth: malformed JWT payload: " + err.Error())
+	}
+	if err := json.Unmarshal(payload, v); err != nil {
+		return errors.New("oauth: malformed JWT claims: " + err.Error())
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+// Copyright 2014 The goauth2 Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package oauth
+
+import (
+	"encoding/base64"
+	"reflect"
+	"testing"
+)
+
+// fakeJWT returns an unsigned JWT carrying the given JSON claims.
+func fakeJWT(claims string) string {
+	enc := base64.RawURLEncoding.EncodeToString
+	return enc([]byte(`{"alg":"RS256","typ":"JWT"}`)) + "." + enc([]byte(claims)) + ".c2lnbmF0dXJl"
+}
+
+func TestIdTokenClaims(t *testing.T) {
+	tests := []struct {
+		idToken string
+		want    *Claims
+	}{
+		{
+			fakeJWT(`{"sub":"110169484474386276334","email":"user@example.net","aud":"cl13nt1d","exp":1419356238}`),
+			&Claims{Sub: "110169484474386276334", Email: "user@example.net", Aud: []string{"cl13nt1d"}, Exp: 1419356238},
+		},
+		{
+			fakeJWT(`{"sub":"1","aud":["cl13nt1d","other"]}`),
+			&Claims{Sub: "1", Aud: []string{"cl13nt1d", "other"}},
+		},
+		{
+			// Padded payload.
+			"eyJhbGciOiJub25lIn0.eyJzdWIiOiIxIn0=.",
+			&Claims{Sub: "1"},
+		},
+		{"", nil},
+		{"not-a-jwt", nil},
+		{"a.!!!.c", nil},
+		{fakeJWT(`not json`), nil},
+		{fakeJWT(`{"aud":7}`), nil},
+	}
+	for _, tt := range tests {
+		tok := &Token{Extra: map[string]string{"id_token": tt.idToken}}
+		c, err := tok.IdTokenClaims()
+		if tt.want == nil {
+			if err == nil {
+				t.Errorf("IdTokenClaims of %q = %+v, want error", tt.idToken, c)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("IdTokenClaims of %q: %v", tt.idToken, err)
+			continue
+		}
+		if !reflect.DeepEqual(c, tt.want) {
+			t.Errorf("IdTokenClaims of %q = %+v, want %+v", tt.idToken, c, tt.want)
+		}
+	}
+}
+
+func TestNonce(t *testing.T) {
+	n1, err := NewNonce()
+	if err != nil {
+		t.Fatalf("NewNonce: %v", err)
+	}
+	n2, _ := NewNonce()
+	if len(n1) < 22 || n1 == n2 {
+		t.Errorf("NewNonce returned %q and %q, want distinct random values", n1, n2)
+	}
+	if g, w := Nonce(n1), (AuthParam{"nonce", n1}); g != w {
+		t.Errorf("Nonce(%q) = %v, want %v", n1, g, w)
+	}
+
+	tests := []struct {
+		idToken, nonce string
+		valid          bool
+	}{
+		{fakeJWT(`{"sub":"1","nonce":"n0nc3"}`), "n0nc3", true},
+		{fakeJWT(`{"sub":"1","nonce":"n0nc3"}`), "other", false},
+		{fakeJWT(`{"sub":"1"}`), "n0nc3", false},
+		{fakeJWT(`{"sub":"1"}`), "", false},
+		{"", "n0nc3", false},
+	}
+	for _, tt := range tests {
+		tok := &Token{Extra: map[string]string{"id_token": tt.idToken}}
+		if err := tok.ValidateNonce(tt.nonce); (err == nil) != tt.valid {
+			t.Errorf("ValidateNonce(%q) of %q = %v, want valid %v", tt.nonce, tt.idToken, err, tt.valid)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+// Copyright 2014 The goauth2 Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package oauth
+
+import (
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// TokenFromFragment returns the Token in the fragment of redirectURL,
+// the URL to which the provider sent the user back after an
+// authorization request whose Config had a ResponseType such as "token"
+// or "id_token token", as in the implicit flow (RFC 6749 section 4.2)
+// and OpenID Connect's hybrid flows. It checks that the state matches
+// wantState, as Handle does, and returns an *AuthError if the provider
+// reports an error.
+//
+// The Token has no RefreshToken. Its Raw field holds every value in the
+// fragment; for a hybrid flow, Raw["code"] is an authorization code that
+// may be exchanged with Exchange. An ID token is in Extra["id_token"],
+// and its nonce must be checked with ValidateNonce. A response without
+// an access token, as for "code id_token", gives a Token with an empty
+// AccessToken, provided it has an ID token or a code.
+//
+// The implicit flow is discouraged (see RFC 9700): the access token is
+// exposed to the browser, its history and any script on the page, and
+// may leak through the Referer header, and the client cannot prove that
+// the token was issued to it. New applications, including single-page
+// ones, should use the authorization code flow with a CodeVerifier.
+func TokenFromFragment(redirectURL, wantState string) (*Token, error) {
+	u, err := url.Parse(redirectURL)
+	if err != nil {
+		return nil, err
+	}
+	vals, err := url.ParseQuery(u.Fragment)
+	if err != nil {
+		return nil, OAuthError{"TokenFromFragment", "invalid fragment: " + err.Error()}
+	}
+	if code := vals.Get("error"); code != "" {
+		return nil, &AuthError{
+			Code:        code,
+			Description: vals.Get("error_description"),
+			URI:         vals.Get("error_uri"),
+		}
+	}
+	if !ValidateState(vals.Get("state"), wantState) {
+		return nil, ErrStateMismatch
+	}
+	tok := &Token{
+		AccessToken: vals.Get("access_token"),
+		TokenType:   vals.Get("token_type"),
+		Scope:       vals.Get("scope"),
+		Raw:         make(map[string]interface{}, len(vals)),
+	}
+	if tok.AccessToken == "" && vals.Get("id_token") == "" && vals.Get("code") == "" {
+		return nil, OAuthError{"TokenFromFragment", "no token in redirect"}
+	}
+	for k := range vals {
+		tok.Raw[k] = vals.Get(k)
+	}
+	if n, _ := strconv.ParseInt(vals.Get("expires_in"), 10, 64); n > 0 {
+		tok.Expiry = time.Now().Add(time.Duration(n) * time.Second)
+	}
+	if id := vals.Get("id_token"); id != "" {
+		tok.Extra = map[string]string{"id_token": id}
+	}
+	return tok, nil
+}
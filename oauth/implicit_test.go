@@ -0,0 +1,77 @@
+// Copyright 2014 The goauth2 Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package oauth
+
+import (
+	"errors"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestResponseType(t *testing.T) {
+	for _, tt := range []struct{ responseType, want string }{
+		{"", "code"},
+		{"token", "token"},
+		{"id_token token", "id_token token"},
+		{"code id_token", "code id_token"},
+	} {
+		config := &Config{ClientId: "cl13nt1d", AuthURL: "https://example.com/auth", ResponseType: tt.responseType}
+		u, err := url.Parse(config.AuthCodeURL("st4t3"))
+		if err != nil {
+			t.Fatalf("AuthCodeURL: %v", err)
+		}
+		if g := u.Query().Get("response_type"); g != tt.want {
+			t.Errorf("ResponseType %q: response_type = %q, want %q", tt.responseType, g, tt.want)
+		}
+	}
+}
+
+func TestTokenFromFragment(t *testing.T) {
+	const redirect = "https://app.example.com/callback#"
+	tok, err := TokenFromFragment(redirect+"access_token=token1&token_type=Bearer&expires_in=3600&scope=email&state=st4t3", "st4t3")
+	if err != nil {
+		t.Fatalf("TokenFromFragment: %v", err)
+	}
+	if tok.AccessToken != "token1" || tok.TokenType != "Bearer" || tok.Scope != "email" || tok.RefreshToken != "" {
+		t.Errorf("TokenFromFragment = %v", tok)
+	}
+	if d := tok.Expiry.Sub(time.Now()); d < 59*time.Minute || d > time.Hour {
+		t.Errorf("Expiry in %v, want 1h", d)
+	}
+	if g := tok.Raw["state"]; g != "st4t3" {
+		t.Errorf(`Raw["state"] = %v, want st4t3`, g)
+	}
+
+	// A hybrid flow returns a code and an ID token without an access token.
+	tok, err = TokenFromFragment(redirect+"code=c0d3&id_token=a.b.c&state=st4t3", "st4t3")
+	if err != nil {
+		t.Fatalf("TokenFromFragment with code and id_token: %v", err)
+	}
+	if tok.AccessToken != "" || tok.Extra["id_token"] != "a.b.c" || tok.Raw["code"] != "c0d3" {
+		t.Errorf("TokenFromFragment with code and id_token = %v, Extra %v, Raw %v", tok, tok.Extra, tok.Raw)
+	}
+
+	tests := []struct {
+		fragment string
+		check    func(error) bool
+	}{
+		{"access_token=token1&state=forged", func(err error) bool { return err == ErrStateMismatch }},
+		{"access_token=token1", func(err error) bool { return err == ErrStateMismatch }},
+		{"error=access_denied&state=st4t3", func(err error) bool {
+			var e *AuthError
+			return errors.As(err, &e) && e.Code == "access_denied"
+		}},
+		{"state=st4t3", func(err error) bool {
+			_, ok := err.(OAuthError)
+			return ok
+		}},
+	}
+	for _, tt := range tests {
+		if tok, err := TokenFromFragment(redirect+tt.fragment, "st4t3"); !tt.check(err) {
+			t.Errorf("TokenFromFragment(%q) = %v, %v", tt.fragment, tok, err)
+		}
+	}
+}
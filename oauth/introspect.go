@@ -0,0 +1,52 @@
+// Copyright 2014 The goauth2 Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package oauth
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/url"
+)
+
+// Introspection describes the state of a token, as reported by the
+// introspection endpoint (RFC 7662 section 2.2).
+//
+// If Active is false the token is not valid (it may have expired or been
+// revoked, or it may never have been issued) and the other fields are
+// typically empty.
+type Introspection struct {
+	Active   bool   `json:"active"`
+	Scope    string `json:"scope"`
+	ClientId string `json:"client_id"`
+	Username string `json:"username"`
+	Exp      int64  `json:"exp"` // expiry in seconds since the Unix epoch
+	Sub      string `json:"sub"`
+}
+
+// Introspect asks the Config's IntrospectURL about the given token.
+// An inactive token is not an error; callers should check the Active
+// field of the result.
+func (t *Transport) Introspect(token string) (*Introspection, error) {
+	if t.Config == nil {
+		return nil, OAuthError{"Introspect", "no Config supplied"}
+	}
+	if t.IntrospectURL == "" {
+		return nil, OAuthError{"Introspect", "no IntrospectURL supplied"}
+	}
+	r, err := t.postForm(context.Background(), t.IntrospectURL, url.Values{"token": {token}})
+	if err != nil {
+		return nil, err
+	}
+	defer r.Body.Close()
+	if r.StatusCode != 200 {
+		return nil, parseTokenError(r)
+	}
+	in := new(Introspection)
+	if err := json.NewDecoder(io.LimitReader(r.Body, 1<<20)).Decode(in); err != nil {
+		return nil, OAuthError{"Introspect", err.Error()}
+	}
+	return in, nil
+}
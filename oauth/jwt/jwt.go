@@ -21,7 +21,7 @@
 //
 //	// Form the request to the service.
 //	req, _ := http.NewRequest("GET", "https://storage.googleapis.com/", nil)
-//	req.Header.Set("Authorization", "OAuth "+o.AccessToken)
+//	req.Header.Set("Authorization", "Bearer "+o.AccessToken)
 //	req.Header.Set("x-goog-api-version", "2")
 //	req.Header.Set("x-goog-project-id", "XXXXXXXXXXXX")
 //
@@ -398,6 +398,7 @@ func handleResponse(r *http.Response) (*oauth.Token, error) {
 		return o, err
 	}
 	o.AccessToken = b.Access
+	o.TokenType = b.Type
 	if b.IdToken != "" {
 		// decode returned id token to get expiry
 		o.AccessToken = b.IdToken
@@ -15,8 +15,11 @@ import (
 	"crypto/x509"
 	"encoding/json"
 	"encoding/pem"
+	"io"
 	"io/ioutil"
 	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 )
@@ -459,11 +462,66 @@ func TestHandleResponseWithNewExpiry(t *testing.T) {
 	}
 }
 
-// Placeholder for future Assert tests.
+// Test that Assert posts a correctly signed assertion and that the
+// Transport uses the resulting access token.
 func TestAssert(t *testing.T) {
-	// Since this method makes a call to BuildRequest, an htttp.Client, and
-	// finally HandleResponse there is not much more to test.  This is here
-	// as a placeholder if that changes.
+	block, _ := pem.Decode(publicKeyPemBytes)
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatalf("TestAssert:x509.ParseCertificate: %v", err)
+	}
+	pubKey := cert.PublicKey.(*rsa.PublicKey)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/token":
+			if g, w := r.FormValue("grant_type"), stdGrantType; g != w {
+				t.Errorf("TestAssert: grant_type = %q, want %q", g, w)
+			}
+			parts := strings.Split(r.FormValue("assertion"), ".")
+			if len(parts) != 3 {
+				t.Errorf("TestAssert: assertion has %d parts, want 3", len(parts))
+				http.Error(w, "malformed assertion", http.StatusInternalServerError)
+				return
+			}
+			sig, err := base64Decode(parts[2])
+			if err != nil {
+				t.Errorf("TestAssert:base64Decode: %v", err)
+				http.Error(w, "malformed signature", http.StatusInternalServerError)
+				return
+			}
+			h := sha256.New()
+			h.Write([]byte(parts[0] + "." + parts[1]))
+			if err := rsa.VerifyPKCS1v15(pubKey, crypto.SHA256, h.Sum(nil), sig); err != nil {
+				t.Errorf("TestAssert: assertion signature does not verify: %v", err)
+			}
+			w.Header().Set("Content-Type", "application/json")
+			io.WriteString(w, `{"access_token":"token1","token_type":"Bearer","expires_in":3600}`)
+		case "/secure":
+			if g, w := r.Header.Get("Authorization"), "Bearer token1"; g != w {
+				t.Errorf("TestAssert: Authorization = %q, want %q", g, w)
+			}
+		}
+	}))
+	defer server.Close()
+
+	tok := NewToken(iss, scope, privateKeyPemBytes)
+	tok.ClaimSet.Aud = server.URL + "/token"
+	tr, err := NewTransport(tok)
+	if err != nil {
+		t.Fatalf("TestAssert:NewTransport: %v", err)
+	}
+	if g, w := tr.OAuthToken.AccessToken, "token1"; g != w {
+		t.Errorf("TestAssert: AccessToken = %q, want %q", g, w)
+	}
+	if g, w := tr.OAuthToken.TokenType, "Bearer"; g != w {
+		t.Errorf("TestAssert: TokenType = %q, want %q", g, w)
+	}
+	resp, err := tr.Client().Get(server.URL + "/secure")
+	if err != nil {
+		t.Fatalf("TestAssert:Get: %v", err)
+	}
+	resp.Body.Close()
 }
 
 // Benchmark for the end-to-end encoding of a well formed token.
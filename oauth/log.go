@@ -0,0 +1,53 @@
+// Copyright 2014 The goauth2 Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package oauth
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+)
+
+// Events passed to Transport.LogEvent.
+const (
+	// EventToken is logged when a Token is obtained from the token
+	// endpoint. Its fields are "grant_type", "access_token", "expiry"
+	// and, if the server issued one, "refresh_token".
+	EventToken = "token"
+
+	// EventTokenError is logged when a request to the token endpoint
+	// fails. Its fields are "grant_type" and "error".
+	EventTokenError = "token_error"
+
+	// EventUnauthorized is logged when a request made with the Token
+	// receives a 401 response. Its fields are "method", "host", "path"
+	// and "retry", which reports whether the request is retried with a
+	// renewed Token.
+	EventUnauthorized = "unauthorized"
+)
+
+// logEvent calls t.LogEvent, if set.
+func (t *Transport) logEvent(event string, fields map[string]interface{}) {
+	if t.LogEvent != nil {
+		t.LogEvent(event, fields)
+	}
+}
+
+// logUnauthorized logs an EventUnauthorized for req.
+func (t *Transport) logUnauthorized(req *http.Request, retry bool) {
+	t.logEvent(EventUnauthorized, map[string]interface{}{
+		"method": req.Method,
+		"host":   req.URL.Host,
+		"path":   req.URL.Path,
+		"retry":  retry,
+	})
+}
+
+// fingerprint returns a short hash of the secret s, which identifies it in
+// logs without revealing it.
+func fingerprint(s string) string {
+	h := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(h[:4])
+}
@@ -0,0 +1,69 @@
+// Copyright 2014 The goauth2 Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package oauth
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLogEvent(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/token":
+			w.Header().Set("Content-Type", "application/json")
+			if r.FormValue("refresh_token") == "bad" {
+				w.WriteHeader(http.StatusBadRequest)
+				io.WriteString(w, `{"error":"invalid_grant"}`)
+				return
+			}
+			io.WriteString(w, `{"access_token":"s3cr3t-access","refresh_token":"s3cr3t-refresh","expires_in":3600}`)
+		case "/secure":
+			if r.Header.Get("Authorization") != "Bearer s3cr3t-access" {
+				w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+				w.WriteHeader(http.StatusUnauthorized)
+			}
+		}
+	}))
+	defer server.Close()
+
+	var events []string
+	transport := &Transport{
+		Config: &Config{TokenURL: server.URL + "/token"},
+		Token:  &Token{AccessToken: "stale", RefreshToken: "refreshtoken1"},
+		LogEvent: func(event string, fields map[string]interface{}) {
+			s := fmt.Sprint(fields)
+			if strings.Contains(s, "s3cr3t") || strings.Contains(s, "refreshtoken1") {
+				t.Errorf("event %s logged a token value: %s", event, s)
+			}
+			events = append(events, event)
+		},
+	}
+	resp, err := transport.Client().Get(server.URL + "/secure")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	resp.Body.Close()
+
+	transport.Token = &Token{AccessToken: "stale", RefreshToken: "bad"}
+	resp, err = transport.Client().Get(server.URL + "/secure")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	resp.Body.Close()
+
+	want := []string{
+		EventToken, EventUnauthorized,
+		EventTokenError, EventUnauthorized,
+	}
+	if !reflect.DeepEqual(events, want) {
+		t.Errorf("events = %q, want %q", events, want)
+	}
+}
@@ -0,0 +1,177 @@
+// Copyright 2014 The goauth2 Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package oauth
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LoginOptions configures Login.
+type LoginOptions struct {
+	// Timeout is how long to wait for the user to complete the
+	// authorization. It defaults to five minutes.
+	Timeout time.Duration
+
+	// Open is called with the URL the user should visit. It defaults to
+	// OpenBrowser, falling back to printing the URL to os.Stderr.
+	Open func(url string) error
+
+	// Params are added to the authorization URL.
+	Params []AuthParam
+
+	// Listen returns the listener for the temporary HTTP server. It
+	// defaults to listening on a 127.0.0.1 port chosen by the system.
+	Listen func() (net.Listener, error)
+
+	// ReadCode is called to obtain the authorization code from the user
+	// if Listen fails. It defaults to prompting on os.Stderr and reading
+	// a line from os.Stdin.
+	ReadCode func() (string, error)
+}
+
+// oobRedirectURL is the redirect URL that asks the provider to show the
+// authorization code to the user, to be copied into the program.
+const oobRedirectURL = "urn:ietf:wg:oauth:2.0:oob"
+
+// Login runs the authorization code flow for a command-line program. It
+// starts a temporary HTTP server on a 127.0.0.1 port, which it uses as the
+// RedirectURL, and sends the user to the authorization URL. Once the
+// user's browser is redirected back, Login completes the flow with Handle
+// and returns a Transport holding the Token, or Handle's error.
+// The Transport's Config is a copy of c with the RedirectURL set.
+// opts may be nil.
+//
+// If no port can be listened on, as on some locked-down machines, Login
+// falls back to the out-of-band flow: the RedirectURL is set to
+// "urn:ietf:wg:oauth:2.0:oob", so that the provider shows the code to
+// the user, and the code the user enters is exchanged for a Token.
+func Login(c *Config, opts *LoginOptions) (*Transport, error) {
+	if opts == nil {
+		opts = &LoginOptions{}
+	}
+	timeout := opts.Timeout
+	if timeout <= 0 {
+		timeout = 5 * time.Minute
+	}
+	open := opts.Open
+	if open == nil {
+		open = func(url string) error {
+			if err := OpenBrowser(url); err != nil {
+				fmt.Fprintf(os.Stderr, "Visit this URL to authorize access:\n\n%s\n\n", url)
+			}
+			return nil
+		}
+	}
+
+	state, err := NewState()
+	if err != nil {
+		return nil, err
+	}
+	verifier, err := NewCodeVerifier()
+	if err != nil {
+		return nil, err
+	}
+	listen := opts.Listen
+	if listen == nil {
+		listen = func() (net.Listener, error) {
+			return net.Listen("tcp", "127.0.0.1:0")
+		}
+	}
+	ln, err := listen()
+	if err != nil {
+		return loginManual(c, opts, open, state, verifier)
+	}
+	defer ln.Close()
+
+	config := *c
+	config.RedirectURL = "http://" + ln.Addr().String() + "/"
+	t := &Transport{Config: &config, CodeVerifier: verifier}
+
+	errc := make(chan error, 1)
+	var once sync.Once
+	go http.Serve(ln, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		once.Do(func() {
+			_, err := t.Handle(r, state)
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				io.WriteString(w, "Authorization failed. You may close this window.\n")
+			} else {
+				io.WriteString(w, "Authorization complete. You may close this window.\n")
+			}
+			errc <- err
+		})
+	}))
+
+	if err := open(t.AuthCodeURL(state, opts.Params...)); err != nil {
+		return nil, err
+	}
+	select {
+	case err := <-errc:
+		if err != nil {
+			return nil, err
+		}
+		return t, nil
+	case <-time.After(timeout):
+		return nil, OAuthError{"Login", "timed out waiting for authorization"}
+	}
+}
+
+// loginManual implements the out-of-band flow for Login.
+func loginManual(c *Config, opts *LoginOptions, open func(string) error, state, verifier string) (*Transport, error) {
+	readCode := opts.ReadCode
+	if readCode == nil {
+		readCode = func() (string, error) {
+			fmt.Fprint(os.Stderr, "Enter the authorization code: ")
+			return bufio.NewReader(os.Stdin).ReadString('\n')
+		}
+	}
+
+	config := *c
+	config.RedirectURL = oobRedirectURL
+	t := &Transport{Config: &config, CodeVerifier: verifier}
+	if err := open(t.AuthCodeURL(state, opts.Params...)); err != nil {
+		return nil, err
+	}
+	code, err := readCode()
+	code = strings.TrimSpace(code)
+	if code == "" {
+		if err == nil {
+			err = OAuthError{"Login", "no authorization code entered"}
+		}
+		return nil, err
+	}
+	if _, err := t.Exchange(code); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// OpenBrowser opens url in the user's web browser.
+func OpenBrowser(url string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("open", url)
+	case "windows":
+		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", url)
+	default:
+		cmd = exec.Command("xdg-open", url)
+	}
+	return cmd.Start()
+}
@@ -0,0 +1,160 @@
+// Copyright 2014 The goauth2 Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package oauth
+
+import (
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+// browser returns an Open function for Login that acts as the user's
+// browser, following the authorization URL to the redirect URL with the
+// given query, to which the state is added unless it is set.
+func browser(t *testing.T, query url.Values) func(string) error {
+	return func(authURL string) error {
+		u, err := url.Parse(authURL)
+		if err != nil {
+			return err
+		}
+		q := u.Query()
+		if q.Get("code_challenge") == "" {
+			t.Errorf("authorization URL %q has no code_challenge", authURL)
+		}
+		v := url.Values{"state": {q.Get("state")}}
+		for k, vs := range query {
+			v[k] = vs
+		}
+		go func() {
+			// Login reports any failure of the redirect.
+			if resp, err := http.Get(q.Get("redirect_uri") + "?" + v.Encode()); err == nil {
+				resp.Body.Close()
+			}
+		}()
+		return nil
+	}
+}
+
+func TestLogin(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if g, w := r.FormValue("code"), "c0d3"; g != w {
+			t.Errorf("code = %q, want %q", g, w)
+		}
+		if r.FormValue("code_verifier") == "" {
+			t.Errorf("no code_verifier sent")
+		}
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"access_token":"token1","refresh_token":"refreshtoken1"}`)
+	}))
+	defer server.Close()
+
+	config := &Config{
+		ClientId: "cl13nt1d",
+		AuthURL:  server.URL + "/auth",
+		TokenURL: server.URL + "/token",
+	}
+	transport, err := Login(config, &LoginOptions{
+		Open: browser(t, url.Values{"code": {"c0d3"}}),
+	})
+	if err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+	if g, w := transport.AccessToken, "token1"; g != w {
+		t.Errorf("AccessToken = %q, want %q", g, w)
+	}
+	if config.RedirectURL != "" {
+		t.Errorf("Login changed the Config's RedirectURL to %q", config.RedirectURL)
+	}
+
+	// A redirect with the wrong state or an error fails.
+	for _, q := range []url.Values{
+		{"code": {"c0d3"}, "state": {"forged"}},
+		{"error": {"access_denied"}},
+	} {
+		if _, err := Login(config, &LoginOptions{Open: browser(t, q)}); err == nil {
+			t.Errorf("Login with redirect query %v succeeded, want error", q)
+		}
+	}
+
+	_, err = Login(config, &LoginOptions{
+		Timeout: 10 * time.Millisecond,
+		Open:    func(string) error { return nil },
+	})
+	if err == nil {
+		t.Errorf("Login without redirect succeeded, want timeout")
+	}
+}
+
+func TestLoginFallback(t *testing.T) {
+	var redirectURL string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		redirectURL = r.FormValue("redirect_uri")
+		if g, w := r.FormValue("code"), "c0d3"; g != w {
+			t.Errorf("code = %q, want %q", g, w)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"access_token":"token1"}`)
+	}))
+	defer server.Close()
+
+	config := &Config{
+		ClientId: "cl13nt1d",
+		AuthURL:  server.URL + "/auth",
+		TokenURL: server.URL + "/token",
+	}
+
+	// With a listener, the code arrives at the loopback redirect.
+	var listened net.Listener
+	transport, err := Login(config, &LoginOptions{
+		Open: browser(t, url.Values{"code": {"c0d3"}}),
+		Listen: func() (net.Listener, error) {
+			ln, err := net.Listen("tcp", "127.0.0.1:0")
+			listened = ln
+			return ln, err
+		},
+		ReadCode: func() (string, error) {
+			t.Errorf("ReadCode called with a listener")
+			return "", nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("Login with listener: %v", err)
+	}
+	if g, w := transport.RedirectURL, "http://"+listened.Addr().String()+"/"; g != w || redirectURL != w {
+		t.Errorf("Login with listener: RedirectURL = %q, sent %q; want %q", g, redirectURL, w)
+	}
+
+	// Without one, the user enters the code.
+	var authURL string
+	transport, err = Login(config, &LoginOptions{
+		Open:     func(u string) error { authURL = u; return nil },
+		Listen:   func() (net.Listener, error) { return nil, errors.New("no ports") },
+		ReadCode: func() (string, error) { return "c0d3\n", nil },
+	})
+	if err != nil {
+		t.Fatalf("Login without listener: %v", err)
+	}
+	if transport.AccessToken != "token1" {
+		t.Errorf("Login without listener: AccessToken = %q, want token1", transport.AccessToken)
+	}
+	u, _ := url.Parse(authURL)
+	if g, w := u.Query().Get("redirect_uri"), oobRedirectURL; g != w || redirectURL != w {
+		t.Errorf("Login without listener: redirect_uri = %q, sent %q; want %q", g, redirectURL, w)
+	}
+
+	_, err = Login(config, &LoginOptions{
+		Open:     func(string) error { return nil },
+		Listen:   func() (net.Listener, error) { return nil, errors.New("no ports") },
+		ReadCode: func() (string, error) { return "", io.EOF },
+	})
+	if err == nil {
+		t.Errorf("Login with no code entered succeeded, want error")
+	}
+}
@@ -33,10 +33,15 @@
 //		// ...
 //		// btw, r.FormValue("state") == "foo"
 //	}
-//
 package oauth
 
 import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -46,6 +51,8 @@ import (
 	"net/http"
 	"net/url"
 	"os"
+	"path/filepath"
+	"reflect"
 	"strconv"
 	"strings"
 	"sync"
@@ -64,6 +71,94 @@ func (oe OAuthError) Error() string {
 	return "OAuthError: " + oe.prefix + ": " + oe.msg
 }
 
+// TokenError is the error returned when the token endpoint responds with
+// an error, as described in RFC 6749 section 5.2.
+type TokenError struct {
+	Code        string // error code, such as "invalid_grant"; empty if none was sent
+	Description string // optional human-readable description
+	URI         string // optional URI of a page describing the error
+
+	// StatusCode is that of the token endpoint's response, and Header
+	// holds those of its headers that help in diagnosing problems with
+	// the provider: Retry-After, rate-limit headers and request IDs to
+	// quote to its support. Other headers, such as Set-Cookie, are left
+	// out, since errors are often logged.
+	StatusCode int
+	Header     http.Header
+}
+
+// errorHeader returns the headers of h to keep in a TokenError.
+func errorHeader(h http.Header) http.Header {
+	h2 := make(http.Header)
+	for k, vs := range h {
+		switch {
+		case k == "Retry-After", k == "X-Request-Id", k == "Request-Id", k == "X-Correlation-Id",
+			strings.HasPrefix(k, "X-Ratelimit-"), strings.HasPrefix(k, "Ratelimit"):
+			h2[k] = append([]string(nil), vs...)
+		}
+	}
+	return h2
+}
+
+func (e *TokenError) Error() string {
+	if e.Code == "" {
+		return fmt.Sprintf("OAuthError: updateToken: Unexpected HTTP status %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+	}
+	if e.Description != "" {
+		return "OAuthError: " + e.Code + ": " + e.Description
+	}
+	return "OAuthError: " + e.Code
+}
+
+// ErrNoRefreshToken is returned by Refresh, and by requests made with a
+// Transport whose Token has expired, when the Token has no RefreshToken.
+// The user must authorize the client again to obtain a new Token.
+var ErrNoRefreshToken error = OAuthError{"Refresh", "Token expired; no Refresh Token"}
+
+// ErrTokenRevoked is returned by Refresh, and by requests made with a
+// Transport, when the token endpoint rejects the RefreshToken with an
+// "invalid_grant" error, usually because the user revoked the client's
+// access. The Transport's Token is then cleared, and the user must
+// authorize the client again. A Token in the TokenCache is not removed,
+// but the Transport remembers that its RefreshToken was rejected and
+// does not try it again.
+//
+// The error returned is not ErrTokenRevoked itself but one that wraps
+// the provider's *TokenError, so test for it with errors.Is:
+//
+//	if errors.Is(err, oauth.ErrTokenRevoked) {
+//		// Ask the user to authorize the client again.
+//	}
+var ErrTokenRevoked error = OAuthError{"Refresh", "Refresh Token revoked or expired"}
+
+// revokedError is the error returned when the token endpoint rejects a
+// RefreshToken. It is ErrTokenRevoked according to errors.Is, and wraps
+// the provider's *TokenError.
+type revokedError struct {
+	refreshToken string
+	err          *TokenError
+}
+
+func (e *revokedError) Error() string {
+	if e.err.Description != "" {
+		return ErrTokenRevoked.Error() + ": " + e.err.Description
+	}
+	return ErrTokenRevoked.Error()
+}
+
+func (e *revokedError) Is(target error) bool {
+	return target == ErrTokenRevoked
+}
+
+func (e *revokedError) Unwrap() error {
+	return e.err
+}
+
+// ErrTransportClosed is returned by requests made with a Transport, and
+// by its methods that contact the provider, after the Transport's Close
+// method has been called.
+var ErrTransportClosed error = OAuthError{"RoundTrip", "Transport closed"}
+
 // Cache specifies the methods that implement a Token cache.
 type Cache interface {
 	Token() (*Token, error)
@@ -71,7 +166,8 @@ type Cache interface {
 }
 
 // CacheFile implements Cache. Its value is the name of the file in which
-// the Token is stored in JSON format.
+// the Token is stored in JSON format. The file is readable only by its
+// owner, and is replaced atomically when a new Token is stored.
 type CacheFile string
 
 func (f CacheFile) Token() (*Token, error) {
@@ -81,22 +177,35 @@ func (f CacheFile) Token() (*Token, error) {
 	}
 	defer file.Close()
 	tok := &Token{}
-	if err := json.NewDecoder(file).Decode(tok); err != nil {
+	if err := tok.Load(file); err != nil {
 		return nil, OAuthError{"CacheFile.Token", err.Error()}
 	}
 	return tok, nil
 }
 
 func (f CacheFile) PutToken(tok *Token) error {
-	file, err := os.OpenFile(string(f), os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0600)
+	dir, name := filepath.Split(string(f))
+	if dir == "" {
+		dir = "."
+	}
+	// Write to a temporary file (created with mode 0600) in the same
+	// directory and rename it into place, so that readers never see a
+	// partially written Token.
+	file, err := ioutil.TempFile(dir, name+".tmp")
 	if err != nil {
 		return OAuthError{"CacheFile.PutToken", err.Error()}
 	}
-	if err := json.NewEncoder(file).Encode(tok); err != nil {
+	if err := tok.Save(file); err != nil {
 		file.Close()
+		os.Remove(file.Name())
 		return OAuthError{"CacheFile.PutToken", err.Error()}
 	}
 	if err := file.Close(); err != nil {
+		os.Remove(file.Name())
+		return OAuthError{"CacheFile.PutToken", err.Error()}
+	}
+	if err := os.Rename(file.Name(), string(f)); err != nil {
+		os.Remove(file.Name())
 		return OAuthError{"CacheFile.PutToken", err.Error()}
 	}
 	return nil
@@ -112,10 +221,27 @@ type Config struct {
 	// the configured OAuth provider.
 	ClientSecret string
 
+	// SecondaryClientSecret, if set, is another client secret, for use
+	// while the ClientSecret is being rotated. If the provider rejects
+	// a request authenticated with one of them as "invalid_client", the
+	// request is repeated with the other, and a Transport keeps using
+	// the secret that worked for later requests. It is not used with a
+	// ClientAuth function or with AuthStyleTLS.
+	SecondaryClientSecret string
+
 	// Scope identifies the level of access being requested. Multiple scope
 	// values should be provided as a space-delimited string.
 	Scope string
 
+	// Scopes, if non-empty, is used instead of Scope. Its values are
+	// joined with ScopeSeparator.
+	Scopes []string
+
+	// ScopeSeparator separates the values of Scopes in requests. If
+	// empty, a space is used, as the specification requires; some
+	// providers, such as older versions of Facebook's, want a comma.
+	ScopeSeparator string
+
 	// AuthURL is the URL the user will be directed to in order to grant
 	// access.
 	AuthURL string
@@ -123,8 +249,49 @@ type Config struct {
 	// TokenURL is the URL used to retrieve OAuth tokens.
 	TokenURL string
 
+	// DeviceAuthURL is the URL used to obtain device codes for the
+	// device authorization grant (RFC 8628). See Transport.DeviceAuth.
+	DeviceAuthURL string
+
+	// RevokeURL is the URL used to revoke tokens (RFC 7009).
+	// See Transport.Revoke.
+	RevokeURL string
+
+	// IntrospectURL is the URL used to query the state of tokens
+	// (RFC 7662). See Transport.Introspect.
+	IntrospectURL string
+
+	// AuthStyle specifies how the client authenticates itself to the
+	// OAuth provider's endpoints. The default chooses a style based on
+	// the TokenURL.
+	AuthStyle AuthStyle
+
+	// ClientAuth, if set, places the client's credentials in requests to
+	// the provider's endpoints in place of AuthStyle, for providers with
+	// requirements that none of the styles meet. See ClientAuthFunc.
+	ClientAuth ClientAuthFunc
+
+	// TokenParams are additional parameters, such as "audience", sent
+	// in every request to the TokenURL. Parameters set by this package
+	// take precedence over those in TokenParams.
+	TokenParams url.Values
+
+	// Resources are the URIs of the resource servers at which the Token
+	// is to be used (RFC 8707). Each is sent as a "resource" parameter in
+	// the authorization request and in every request to the TokenURL,
+	// so that the provider can restrict the Token to those audiences.
+	Resources []string
+
+	// TokenRequestJSON makes requests to the TokenURL send the
+	// parameters as a JSON object rather than form-encoded, for the few
+	// providers that require it. A parameter with several values is sent
+	// as an array. The default of form-encoding is what RFC 6749 requires.
+	TokenRequestJSON bool
+
 	// RedirectURL is the URL to which the user will be returned after
-	// granting (or denying) access.
+	// granting (or denying) access. The same value is sent to the token
+	// endpoint by Exchange, as providers require. To choose among several
+	// registered redirect URLs, use WithRedirectURL for both steps.
 	RedirectURL string
 
 	// TokenCache allows tokens to be cached for subsequent requests.
@@ -141,6 +308,14 @@ type Config struct {
 	// code for a user.
 	AccessType string
 
+	// Offline asks the provider to issue a refresh token, so that the
+	// client can renew access tokens while the user is away. For Google,
+	// whose AuthURL is on accounts.google.com, AuthCodeURL sets
+	// access_type to "offline" unless AccessType is set; for other
+	// providers it requests the OpenID Connect "offline_access" scope.
+	// (Some of those also require Prompt("consent") for this.)
+	Offline bool
+
 	// ApprovalPrompt indicates whether the user should be
 	// re-prompted for consent. If set to "auto" (default) the
 	// user will be prompted only if they haven't previously
@@ -149,6 +324,142 @@ type Config struct {
 	// If set to "force" the user will always be prompted, and the
 	// code can be exchanged for a refresh token.
 	ApprovalPrompt string
+
+	// ResponseType is the response_type parameter of the authorization
+	// URL. If empty, "code" is used, for the authorization code flow.
+	// Other values, such as "token" for the implicit flow or
+	// "code id_token" for an OpenID Connect hybrid flow, make the
+	// provider return tokens in the fragment of the redirect URL, from
+	// which TokenFromFragment extracts them; see its documentation for
+	// why they should be avoided.
+	ResponseType string
+
+	// ExpiryDelta is how long before its Expiry a Token is treated as
+	// expired and refreshed by a Transport, allowing for differences
+	// between the local clock and the provider's. If zero,
+	// DefaultExpiryDelta is used. If negative, tokens are only refreshed
+	// once they have actually expired.
+	ExpiryDelta time.Duration
+
+	// StreamExpiryDelta is how long before its Expiry a Token is
+	// refreshed by a Transport before sending a request whose body
+	// cannot be sent again, such as a streaming upload, since such a
+	// request is not retried after a 401 response. If zero,
+	// DefaultStreamExpiryDelta is used. It has no effect if it is less
+	// than the ExpiryDelta.
+	StreamExpiryDelta time.Duration
+
+	// ExpiryFromJWT makes a Transport take the Expiry of a Token whose
+	// response has no expires_in from the "exp" claim of the access
+	// token, if the access token is a JWT. Some providers give the
+	// lifetime of their tokens only in this way. The JWT's signature
+	// is not checked; the claim is used only to decide when to refresh.
+	ExpiryFromJWT bool
+
+	// AllowInsecure permits AuthURL, TokenURL, RedirectURL and the
+	// other endpoint URLs to use plain http. By default they must use
+	// https unless they refer to localhost, so that the client's
+	// credentials and codes are not sent in the clear.
+	AllowInsecure bool
+
+	// AuthScheme, if set, is the scheme used in the Authorization header
+	// of requests made by a Transport, in place of the one given by the
+	// Token's type. Some APIs that predate the Bearer convention expect
+	// another word, such as "token" for GitHub's legacy API.
+	// WithAuthScheme overrides it for a single request.
+	AuthScheme string
+}
+
+// checkScheme returns an error if the named Config field, whose value is
+// s, is a plain http URL that is not allowed by c.AllowInsecure.
+func (c *Config) checkScheme(prefix, field, s string) error {
+	if c.AllowInsecure {
+		return nil
+	}
+	u, err := url.Parse(s)
+	if err != nil {
+		return OAuthError{prefix, field + " malformed: " + err.Error()}
+	}
+	if u.Scheme != "http" {
+		return nil
+	}
+	switch u.Hostname() {
+	case "localhost", "127.0.0.1", "::1":
+		return nil
+	}
+	return OAuthError{prefix, field + " must use https: " + s}
+}
+
+// AuthStyle describes how the client's credentials are sent to the
+// OAuth provider.
+type AuthStyle int
+
+const (
+	// AuthStyleDefault uses HTTP Basic authentication, except for the
+	// providers known to accept the credentials only in the request body.
+	AuthStyleDefault AuthStyle = iota
+
+	// AuthStyleInParams sends the client_id and client_secret in the
+	// request body.
+	AuthStyleInParams
+
+	// AuthStyleInHeader sends the client_id and client_secret using
+	// HTTP Basic authentication, as recommended by RFC 6749 section 2.3.1.
+	AuthStyleInHeader
+
+	// AuthStyleAutoDetect first sends the credentials in the request
+	// body and, if the provider rejects them, tries HTTP Basic
+	// authentication. The style that works is remembered by the
+	// Transport for subsequent requests; a server error or a 429
+	// response is not taken to show which style works.
+	AuthStyleAutoDetect
+
+	// AuthStyleTLS sends only the client_id, relying on the TLS client
+	// certificate presented to the provider for authentication, as
+	// described in RFC 8705. The certificate is configured in the
+	// TLSClientConfig of the Transport's TokenClient. Providers that
+	// issue certificate-bound tokens also require the same certificate
+	// on requests made with the Token, so the Transport's own Transport
+	// usually needs it too.
+	AuthStyleTLS
+)
+
+// A ClientAuthFunc authenticates the client in a POST request to one of
+// the provider's endpoints, by adding credentials to req's header or to
+// form, the parameters that will make up the request body. Empty
+// parameters are removed from form afterwards.
+//
+// For example, for a provider that wants the client_id in the body but
+// the client_secret alone in a Basic Authorization header:
+//
+//	config.ClientAuth = func(req *http.Request, form url.Values) {
+//		form.Set("client_id", config.ClientId)
+//		req.SetBasicAuth("", config.ClientSecret)
+//	}
+type ClientAuthFunc func(req *http.Request, form url.Values)
+
+// ClientAuthInParams returns the ClientAuthFunc used for
+// AuthStyleInParams, which sends id and secret as the client_id and
+// client_secret parameters.
+func ClientAuthInParams(id, secret string) ClientAuthFunc {
+	return func(req *http.Request, form url.Values) {
+		form.Set("client_id", id)
+		form.Set("client_secret", secret)
+	}
+}
+
+// ClientAuthInHeader returns the ClientAuthFunc used for
+// AuthStyleInHeader, which sends id as the client_id parameter and, if
+// secret is not empty, both using HTTP Basic authentication.
+func ClientAuthInHeader(id, secret string) ClientAuthFunc {
+	return func(req *http.Request, form url.Values) {
+		form.Set("client_id", id)
+		if secret != "" {
+			// RFC 6749 section 2.3.1 requires the credentials to be
+			// form-encoded before they are Base64 encoded.
+			req.SetBasicAuth(url.QueryEscape(id), url.QueryEscape(secret))
+		}
+	}
 }
 
 // Token contains an end-user's tokens.
@@ -156,32 +467,160 @@ type Config struct {
 type Token struct {
 	AccessToken  string
 	RefreshToken string
-	Expiry       time.Time // If zero the token has no (known) expiry time.
+
+	// Expiry is the absolute time at which the AccessToken expires,
+	// computed from the server's relative "expires_in" value when the
+	// token is received. The relative value is not kept, so a stored
+	// Token keeps its meaning when it is loaded again. For providers
+	// that omit expires_in, a relative "expires" value or an absolute
+	// "expires_at" value, in Unix seconds or RFC 3339 format, is used.
+	// If zero the token has no (known) expiry time.
+	Expiry time.Time
+
+	// TokenType is the type of the AccessToken, as reported by the
+	// server. If empty, "Bearer" is assumed.
+	TokenType string
+
+	// Scope is the scope of the AccessToken as reported by the server,
+	// which may be narrower than the scope requested if the user did not
+	// grant all of it. It is empty if the server has never reported
+	// it. See GrantedScopes.
+	Scope string
 
 	// Extra optionally contains extra metadata from the server
 	// when updating a token. The only current key that may be
 	// populated is "id_token". It may be nil and will be
 	// initialized as needed.
 	Extra map[string]string
+
+	// Raw holds every field of the token endpoint's most recent response,
+	// including provider-specific ones such as GitHub's "scope". Values
+	// decoded from JSON have the types used by encoding/json; those from
+	// a form-encoded response are strings. It is nil for a Token that did
+	// not come from a token endpoint.
+	Raw map[string]interface{}
+}
+
+// Save writes t to w in the format read by Load and used by CacheFile:
+// a JSON object whose members are named after the Token's fields. The
+// Expiry is written as an absolute time in RFC 3339 format, so a Token
+// that is saved and loaded again expires at the same moment.
+func (t *Token) Save(w io.Writer) error {
+	return json.NewEncoder(w).Encode(t)
+}
+
+// Load replaces t with the Token read from r, which must be in the
+// format written by Save.
+func (t *Token) Load(r io.Reader) error {
+	var tok Token
+	if err := json.NewDecoder(r).Decode(&tok); err != nil {
+		return err
+	}
+	*t = tok
+	return nil
+}
+
+// Refreshable reports whether the token has a RefreshToken, with which a
+// new access token can be obtained without involving the user.
+func (t *Token) Refreshable() bool {
+	return t.RefreshToken != ""
+}
+
+// GrantedScopes returns the scopes in the Token's Scope. They are
+// normally separated by spaces, but some providers, such as GitHub and
+// Facebook, separate them with commas; either is accepted.
+func (t *Token) GrantedScopes() []string {
+	return splitScope(t.Scope)
+}
+
+// Type returns the authorization scheme to use with the AccessToken,
+// normalizing the case of the well-known types.
+func (t *Token) Type() string {
+	switch {
+	case t.TokenType == "", strings.EqualFold(t.TokenType, "bearer"):
+		return "Bearer"
+	case strings.EqualFold(t.TokenType, "mac"):
+		return "MAC"
+	case strings.EqualFold(t.TokenType, "basic"):
+		return "Basic"
+	}
+	return t.TokenType
+}
+
+// String returns a description of t for logging in which the
+// AccessToken and RefreshToken are redacted, showing only their length
+// and, for long values, a short prefix. It has a value receiver so that
+// both Token and *Token values are formatted safely by the fmt package.
+// Use Unredacted when the secrets are genuinely needed.
+func (t Token) String() string {
+	return fmt.Sprintf("oauth.Token{AccessToken: %s, RefreshToken: %s, Expiry: %v, TokenType: %q, Scope: %q}",
+		redact(t.AccessToken), redact(t.RefreshToken), t.Expiry, t.TokenType, t.Scope)
+}
+
+// GoString is like String; it keeps the %#v verb from printing the
+// secrets.
+func (t Token) GoString() string {
+	return t.String()
+}
+
+// Unredacted returns a description of every field of t, including the
+// secret ones, such as the fmt package would produce without String.
+// It should not be used for logging.
+func (t *Token) Unredacted() string {
+	type token Token // without the String method
+	return fmt.Sprintf("%+v", token(*t))
+}
+
+// redact returns a quoted stand-in for the secret s.
+func redact(s string) string {
+	switch {
+	case s == "":
+		return `""`
+	case len(s) < 16:
+		return fmt.Sprintf(`"..." (%d bytes)`, len(s))
+	}
+	return fmt.Sprintf(`"%s..." (%d bytes)`, s[:4], len(s))
 }
 
-// Expired reports whether the token has expired or is invalid.
+// DefaultExpiryDelta is how long before its Expiry a Token is treated as
+// expired, unless the Config's ExpiryDelta says otherwise.
+const DefaultExpiryDelta = 10 * time.Second
+
+// DefaultStreamExpiryDelta is the default value of a Config's
+// StreamExpiryDelta.
+const DefaultStreamExpiryDelta = 5 * time.Minute
+
+// DefaultTokenRequestTimeout is the default value of a Transport's
+// TokenRequestTimeout.
+const DefaultTokenRequestTimeout = 30 * time.Second
+
+// Expired reports whether the token has expired, will expire within
+// DefaultExpiryDelta, or is invalid.
+// A token without an AccessToken is always considered expired.
+// A token with a zero Expiry has no known expiry time, so it is
+// never considered expired on the basis of time alone.
 func (t *Token) Expired() bool {
+	return t.expiresWithin(time.Now(), DefaultExpiryDelta)
+}
+
+// expiresWithin reports whether, at time now, the token has expired or
+// will expire within d.
+func (t *Token) expiresWithin(now time.Time, d time.Duration) bool {
 	if t.AccessToken == "" {
 		return true
 	}
 	if t.Expiry.IsZero() {
 		return false
 	}
-	return t.Expiry.Before(time.Now())
+	return t.Expiry.Add(-d).Before(now)
 }
 
 // Transport implements http.RoundTripper. When configured with a valid
 // Config and Token it can be used to make authenticated HTTP requests.
 //
 //	t := &oauth.Transport{config}
-//      t.Exchange(code)
-//      // t now contains a valid Token
+//	t.Exchange(code)
+//	// t now contains a valid Token
 //	r, _, err := t.Client().Get("http://example.org/url/requiring/auth")
 //
 // It will automatically refresh the Token if it can,
@@ -193,15 +632,262 @@ type Transport struct {
 	// mu guards modifying the token.
 	mu sync.Mutex
 
+	// refreshing is the refresh in progress, if any. It is guarded by mu.
+	refreshing *refreshCall
+
+	// revoked is the error for the last RefreshToken rejected by the
+	// token endpoint, if any. It is guarded by mu.
+	revoked *revokedError
+
+	// styleMu guards detectedStyle, the AuthStyle found to work when
+	// the Config's AuthStyle is AuthStyleAutoDetect.
+	styleMu       sync.Mutex
+	detectedStyle AuthStyle
+
+	// useSecondary records that the provider last accepted the Config's
+	// SecondaryClientSecret. It is guarded by styleMu.
+	useSecondary bool
+
+	// clientMu guards the clients returned by Client and tokenClient,
+	// which are made once and reused; tokenClientRT is the RoundTripper
+	// that tokenHTTPClient was made with. It also guards closed, which
+	// is set by Close.
+	clientMu        sync.Mutex
+	client          *http.Client
+	tokenHTTPClient *http.Client
+	tokenClientRT   http.RoundTripper
+	closed          bool
+
+	// TokenStyle says where RoundTrip puts the access token. By default
+	// it is sent in the Authorization header.
+	TokenStyle TokenStyle
+
+	// TokenSource, if non-nil, supplies the Tokens used by RoundTrip in
+	// place of the Transport's own Token, which is then neither used nor
+	// renewed. A TokenSource may be shared by several Transports.
+	TokenSource TokenSource
+
 	// Transport is the HTTP transport to use when making requests.
 	// It will default to http.DefaultTransport if nil.
 	// (It should never be an oauth.Transport.)
 	Transport http.RoundTripper
+
+	// TokenClient, if non-nil, is the HTTP client used for requests to
+	// the provider's token, device authorization, revocation and
+	// introspection endpoints, which are authenticated with the client's
+	// credentials rather than the Token. Use it to give those requests a
+	// different timeout, proxy or TLS configuration. If nil, a client
+	// using Transport is used. (Its Transport should never be an
+	// oauth.Transport.)
+	TokenClient *http.Client
+
+	// CodeVerifier is the PKCE (RFC 7636) code verifier for the current
+	// authorization request. If set, AuthCodeURL includes the matching
+	// code challenge and Exchange sends the verifier to the server.
+	// Use NewCodeVerifier to create one.
+	//
+	// The verifier, like the state passed to AuthCodeURL, belongs to the
+	// caller rather than to the Transport. Where the redirect may be
+	// handled by another process than the one that built the URL, as in
+	// a load-balanced web app, store both in a session shared between
+	// them and set CodeVerifier on a new Transport before calling Handle
+	// or Exchange:
+	//
+	//	// Building the URL:
+	//	state, _ := oauth.NewState()
+	//	verifier, _ := oauth.NewCodeVerifier()
+	//	session.Set("state", state)
+	//	session.Set("verifier", verifier)
+	//	t := &oauth.Transport{Config: config, CodeVerifier: verifier}
+	//	http.Redirect(w, r, t.AuthCodeURL(state), http.StatusFound)
+	//
+	//	// Handling the redirect, perhaps elsewhere:
+	//	t := &oauth.Transport{Config: config, CodeVerifier: session.Get("verifier")}
+	//	tok, err := t.Handle(r, session.Get("state"))
+	CodeVerifier string
+
+	// TokenRefreshed, if non-nil, is called with the new Token whenever
+	// one is obtained from the server, whether by Exchange, Refresh or
+	// another grant, so that it may be persisted. An error returned by
+	// TokenRefreshed is returned by the method that obtained the Token.
+	// Like OnExchange, OnRefresh and the TokenCache, it is called without
+	// the Transport's lock held, so it may use the Transport, but not to
+	// refresh the Token.
+	TokenRefreshed func(*Token) error
+
+	// MaxRetries is the number of times a request to the token endpoint
+	// is retried after a network error, a 5xx response or a 429 (Too
+	// Many Requests) response. The default of zero disables retries.
+	// Exchanges of an authorization code, which may be used only once,
+	// are never retried. A wait between retries ends early if the
+	// context of the request is done, and the context's error is
+	// returned.
+	MaxRetries int
+
+	// RetryBackoff is the delay before the first retry; each later retry
+	// waits twice as long as the one before, with random jitter.
+	// It defaults to 500ms. If the response has a Retry-After header,
+	// the delay it gives is used instead.
+	RetryBackoff time.Duration
+
+	// Backoff, if non-nil, chooses the delays between retries in place
+	// of RetryBackoff. A Retry-After header still takes precedence.
+	Backoff Backoff
+
+	// MaxRetryAfter is the longest Retry-After delay that will be
+	// waited for. A response asking for a longer delay is returned
+	// without retrying. It defaults to one minute.
+	MaxRetryAfter time.Duration
+
+	// TokenRequestTimeout bounds each request to the provider's token,
+	// device authorization, revocation and introspection endpoints,
+	// including reading the response, independently of any deadline on
+	// the context or timeout on the TokenClient. If zero,
+	// DefaultTokenRequestTimeout is used. If negative, there is no limit.
+	TokenRequestTimeout time.Duration
+
+	// LogEvent, if non-nil, is called when the Transport obtains a Token,
+	// fails to obtain one, or has a request rejected with a 401 response.
+	// The event is one of the Event constants, which document the fields.
+	// Token values are never logged; only a short SHA-256 fingerprint.
+	// LogEvent must not call methods on the Transport.
+	LogEvent func(event string, fields map[string]interface{})
+
+	// OnExchange and OnRefresh, if non-nil, are called after each
+	// attempt to exchange an authorization code or refresh the Token,
+	// with the time taken by the token endpoint and the resulting
+	// error, which is nil on success. On401Retry, if non-nil, is called
+	// each time RoundTrip retries a request rejected with a 401 response.
+	// They are intended for collecting metrics, and must not call
+	// methods on the Transport.
+	OnExchange func(d time.Duration, err error)
+	OnRefresh  func(d time.Duration, err error)
+	On401Retry func()
+
+	// PrepareTokenRequest, if non-nil, is called with each request to the
+	// provider's token, device authorization, revocation and
+	// introspection endpoints just before it is sent, to add headers
+	// or sign the request. It may read the body, but the body is sent
+	// as it was made regardless.
+	PrepareTokenRequest func(*http.Request)
+
+	// UserAgent, if set, is sent as the User-Agent header of requests to
+	// the provider's endpoints and of the requests that RoundTrip
+	// authorizes, so that the provider can identify the application.
+	// A User-Agent header already set on a request is left alone.
+	UserAgent string
+
+	// nowFunc, if non-nil, returns the current time in place of
+	// time.Now. It is set by tests.
+	nowFunc func() time.Time
+}
+
+// NewTransport returns a Transport for c after checking that c has a
+// ClientId, that its AuthURL and TokenURL are absolute URLs, and that its
+// URLs use https as required by AllowInsecure.
+// A Transport created directly, without NewTransport, is not checked.
+func NewTransport(c *Config) (*Transport, error) {
+	if c == nil {
+		return nil, OAuthError{"NewTransport", "no Config supplied"}
+	}
+	if c.ClientId == "" {
+		return nil, OAuthError{"NewTransport", "no ClientId supplied"}
+	}
+	if _, err := parseAbsURL("NewTransport", "AuthURL", c.AuthURL); err != nil {
+		return nil, err
+	}
+	if _, err := parseAbsURL("NewTransport", "TokenURL", c.TokenURL); err != nil {
+		return nil, err
+	}
+	for _, f := range []struct{ name, url string }{
+		{"AuthURL", c.AuthURL},
+		{"TokenURL", c.TokenURL},
+		{"RedirectURL", c.RedirectURL},
+	} {
+		if err := c.checkScheme("NewTransport", f.name, f.url); err != nil {
+			return nil, err
+		}
+	}
+	return &Transport{Config: c}, nil
+}
+
+// String returns a description of t for logging, which shows the
+// Config's ClientId and the Token as formatted by Token.String, but not
+// the ClientSecret or the Token's secrets.
+func (t *Transport) String() string {
+	t.mu.Lock()
+	tok := t.Token
+	t.mu.Unlock()
+	var id string
+	if t.Config != nil {
+		id = t.ClientId
+	}
+	if tok == nil {
+		return fmt.Sprintf("oauth.Transport{ClientId: %q, Token: <nil>}", id)
+	}
+	return fmt.Sprintf("oauth.Transport{ClientId: %q, Token: %v}", id, tok)
 }
 
 // Client returns an *http.Client that makes OAuth-authenticated requests.
+// Every call returns the same Client.
 func (t *Transport) Client() *http.Client {
-	return &http.Client{Transport: t}
+	t.clientMu.Lock()
+	defer t.clientMu.Unlock()
+	if t.client == nil {
+		t.client = &http.Client{Transport: t}
+	}
+	return t.client
+}
+
+// Close releases the resources held by t, for use when an application
+// shuts down. Later requests made with t, and requests to the provider's
+// endpoints, fail with ErrTransportClosed; requests already in progress
+// are not interrupted. Close closes the idle connections of the
+// Transport's Transport and of its TokenClient's, if they are set, but
+// not those of http.DefaultTransport, which may be shared. If the
+// TokenCache implements io.Closer, Close closes it and returns its
+// error. Calls of Close after the first do nothing and return nil.
+func (t *Transport) Close() error {
+	t.clientMu.Lock()
+	closed := t.closed
+	t.closed = true
+	t.clientMu.Unlock()
+	if closed {
+		return nil
+	}
+
+	type closeIdler interface {
+		CloseIdleConnections()
+	}
+	rts := []http.RoundTripper{t.Transport}
+	if t.TokenClient != nil {
+		rts = append(rts, t.TokenClient.Transport)
+	}
+	for _, rt := range rts {
+		if c, ok := rt.(closeIdler); ok {
+			c.CloseIdleConnections()
+		}
+	}
+	if t.Config != nil {
+		if c, ok := t.TokenCache.(io.Closer); ok {
+			return c.Close()
+		}
+	}
+	return nil
+}
+
+// isClosed reports whether Close has been called.
+func (t *Transport) isClosed() bool {
+	t.clientMu.Lock()
+	defer t.clientMu.Unlock()
+	return t.closed
+}
+
+func (t *Transport) now() time.Time {
+	if t.nowFunc != nil {
+		return t.nowFunc()
+	}
+	return time.Now()
 }
 
 func (t *Transport) transport() http.RoundTripper {
@@ -211,170 +897,1032 @@ func (t *Transport) transport() http.RoundTripper {
 	return http.DefaultTransport
 }
 
+// AuthParam is an additional query parameter for AuthCodeURL.
+type AuthParam struct {
+	Key, Value string
+}
+
 // AuthCodeURL returns a URL that the end-user should be redirected to,
 // so that they may obtain an authorization code.
-func (c *Config) AuthCodeURL(state string) string {
+//
+// Any params are added to the URL's query. A parameter may be given more
+// than once; each value is included. If a param has the same key as a
+// parameter that AuthCodeURL sets from the Config (such as "client_id" or
+// "access_type"), the Config's value is used.
+func (c *Config) AuthCodeURL(state string, params ...AuthParam) string {
 	url_, err := url.Parse(c.AuthURL)
 	if err != nil {
 		panic("AuthURL malformed: " + err.Error())
 	}
-	q := url.Values{
-		"response_type":   {"code"},
-		"client_id":       {c.ClientId},
-		"state":           condVal(state),
-		"scope":           condVal(c.Scope),
-		"redirect_uri":    condVal(c.RedirectURL),
-		"access_type":     condVal(c.AccessType),
-		"approval_prompt": condVal(c.ApprovalPrompt),
-	}.Encode()
-	if url_.RawQuery == "" {
-		url_.RawQuery = q
-	} else {
-		url_.RawQuery += "&" + q
-	}
-	return url_.String()
-}
-
-func condVal(v string) []string {
-	if v == "" {
-		return nil
-	}
-	return []string{v}
+	return c.authCodeURL(url_, state, params)
 }
 
-// Exchange takes a code and gets access Token from the remote server.
-func (t *Transport) Exchange(code string) (*Token, error) {
-	if t.Config == nil {
-		return nil, OAuthError{"Exchange", "no Config supplied"}
-	}
-
-	// If the transport or the cache already has a token, it is
-	// passed to `updateToken` to preserve existing refresh token.
-	tok := t.Token
-	if tok == nil && t.TokenCache != nil {
-		tok, _ = t.TokenCache.Token()
+// BuildAuthCodeURL is like AuthCodeURL, but it returns an error instead of
+// panicking if the Config's AuthURL is malformed or is not an absolute URL.
+// It also returns an error if the AuthURL or RedirectURL uses plain http
+// without AllowInsecure, or if a "prompt" param is not valid (see Prompt).
+// Use it when the Config is loaded at run time.
+func (c *Config) BuildAuthCodeURL(state string, params ...AuthParam) (string, error) {
+	url_, err := parseAbsURL("BuildAuthCodeURL", "AuthURL", c.AuthURL)
+	if err != nil {
+		return "", err
 	}
-	if tok == nil {
-		tok = new(Token)
+	if err := c.checkScheme("BuildAuthCodeURL", "AuthURL", c.AuthURL); err != nil {
+		return "", err
 	}
-	err := t.updateToken(tok, url.Values{
-		"grant_type":   {"authorization_code"},
-		"redirect_uri": {t.RedirectURL},
-		"scope":        {t.Scope},
-		"code":         {code},
-	})
-	if err != nil {
-		return nil, err
+	if err := c.checkScheme("BuildAuthCodeURL", "RedirectURL", c.RedirectURL); err != nil {
+		return "", err
 	}
-	t.Token = tok
-	if t.TokenCache != nil {
-		return tok, t.TokenCache.PutToken(tok)
+	for _, p := range params {
+		if p.Key == "prompt" {
+			if err := checkPrompt(p.Value); err != nil {
+				return "", err
+			}
+		}
 	}
-	return tok, nil
+	return c.authCodeURL(url_, state, params), nil
 }
 
-// RoundTrip executes a single HTTP transaction using the Transport's
-// Token as authorization headers.
+// AuthCodeParams returns the query parameters of the URL that
+// BuildAuthCodeURL would return, or its error. It also returns an error if
+// the Config has no ClientId. It is intended for tests that check a
+// Config is complete before any user is sent to the provider:
 //
-// This method will attempt to renew the Token if it has expired and may return
-// an error related to that Token renewal before attempting the client request.
-// If the Token cannot be renewed a non-nil os.Error value will be returned.
-// If the Token is invalid callers should expect HTTP-level errors,
-// as indicated by the Response's StatusCode.
-func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
-	accessToken, err := t.getAccessToken()
+//	v, err := config.AuthCodeParams("state")
+//	if err != nil || v.Get("redirect_uri") != want {
+//		...
+//	}
+func (c *Config) AuthCodeParams(state string, params ...AuthParam) (url.Values, error) {
+	if c.ClientId == "" {
+		return nil, OAuthError{"AuthCodeParams", "no ClientId supplied"}
+	}
+	s, err := c.BuildAuthCodeURL(state, params...)
 	if err != nil {
 		return nil, err
 	}
-	// To set the Authorization header, we must make a copy of the Request
-	// so that we don't modify the Request we were given.
-	// This is required by the specification of http.RoundTripper.
-	req = cloneRequest(req)
-	req.Header.Set("Authorization", "Bearer "+accessToken)
+	u, err := url.Parse(s)
+	if err != nil {
+		return nil, OAuthError{"AuthCodeParams", err.Error()}
+	}
+	return u.Query(), nil
+}
 
-	// Make the HTTP request.
-	return t.transport().RoundTrip(req)
+// LoginHint returns an AuthParam that suggests to the provider which
+// account the user should sign in with, such as their email address.
+func LoginHint(hint string) AuthParam {
+	return AuthParam{"login_hint", hint}
 }
 
-func (t *Transport) getAccessToken() (string, error) {
-	t.mu.Lock()
-	defer t.mu.Unlock()
+// Prompt returns an AuthParam that asks the provider to prompt the user
+// as described by the values, which are among:
+//
+//	"none"           - don't show any pages; fail if the user must act
+//	"consent"        - ask for consent even if previously given
+//	"select_account" - ask the user to choose an account
+//	"login"          - ask the user to sign in again
+//
+// "none" may not be combined with other values. Invalid values are
+// reported by BuildAuthCodeURL.
+func Prompt(values ...string) AuthParam {
+	return AuthParam{"prompt", strings.Join(values, " ")}
+}
 
-	if t.Token == nil {
-		if t.Config == nil {
-			return "", OAuthError{"RoundTrip", "no Config supplied"}
-		}
-		if t.TokenCache == nil {
-			return "", OAuthError{"RoundTrip", "no Token supplied"}
-		}
-		var err error
-		t.Token, err = t.TokenCache.Token()
-		if err != nil {
-			return "", err
+// checkPrompt returns an error if p is not a valid prompt value.
+func checkPrompt(p string) error {
+	values := strings.Fields(p)
+	if len(values) == 0 {
+		return OAuthError{"BuildAuthCodeURL", "empty prompt"}
+	}
+	for _, v := range values {
+		switch v {
+		case "none":
+			if len(values) > 1 {
+				return OAuthError{"BuildAuthCodeURL", "prompt none combined with other values"}
+			}
+		case "consent", "select_account", "login":
+		default:
+			return OAuthError{"BuildAuthCodeURL", "unknown prompt value " + strconv.Quote(v)}
 		}
 	}
+	return nil
+}
 
-	// Refresh the Token if it has expired.
-	if t.Expired() {
-		if err := t.Refresh(); err != nil {
-			return "", err
-		}
+// parseAbsURL parses s, the value of the named Config field, and checks
+// that it is an absolute URL.
+func parseAbsURL(prefix, field, s string) (*url.URL, error) {
+	u, err := url.Parse(s)
+	if err != nil {
+		return nil, OAuthError{prefix, field + " malformed: " + err.Error()}
 	}
-	if t.AccessToken == "" {
-		return "", errors.New("no access token obtained from refresh")
+	if u.Scheme == "" || u.Host == "" {
+		return nil, OAuthError{prefix, field + " not absolute: " + s}
 	}
-	return t.AccessToken, nil
+	return u, nil
 }
 
-// cloneRequest returns a clone of the provided *http.Request.
-// The clone is a shallow copy of the struct and its Header map.
-func cloneRequest(r *http.Request) *http.Request {
-	// shallow copy of the struct
-	r2 := new(http.Request)
-	*r2 = *r
-	// deep copy of the Header
-	r2.Header = make(http.Header)
-	for k, s := range r.Header {
-		r2.Header[k] = s
-	}
-	return r2
+// AuthCodeURL is like Config.AuthCodeURL, but the URL also carries the
+// PKCE code challenge for the Transport's CodeVerifier, if any.
+func (t *Transport) AuthCodeURL(state string, params ...AuthParam) string {
+	return t.Config.AuthCodeURL(state, t.pkceParams(params)...)
 }
 
-// Refresh renews the Transport's AccessToken using its RefreshToken.
-func (t *Transport) Refresh() error {
-	if t.Token == nil {
-		return OAuthError{"Refresh", "no existing Token"}
+// BuildAuthCodeURL is like Config.BuildAuthCodeURL, but the URL also
+// carries the PKCE code challenge for the Transport's CodeVerifier, if any.
+func (t *Transport) BuildAuthCodeURL(state string, params ...AuthParam) (string, error) {
+	return t.Config.BuildAuthCodeURL(state, t.pkceParams(params)...)
+}
+
+// pkceParams returns params with the code challenge parameters appended
+// if the Transport has a CodeVerifier.
+func (t *Transport) pkceParams(params []AuthParam) []AuthParam {
+	if t.CodeVerifier == "" {
+		return params
 	}
-	if t.RefreshToken == "" {
-		return OAuthError{"Refresh", "Token expired; no Refresh Token"}
+	return append(params[:len(params):len(params)],
+		AuthParam{"code_challenge", CodeChallenge(t.CodeVerifier)},
+		AuthParam{"code_challenge_method", "S256"})
+}
+
+func (c *Config) authCodeURL(url_ *url.URL, state string, params []AuthParam) string {
+	scope, accessType := c.scope(), c.AccessType
+	if c.Offline {
+		if url_.Host == "accounts.google.com" {
+			if accessType == "" {
+				accessType = "offline"
+			}
+		} else if !hasScope(scope, "offline_access") {
+			scope = c.joinScopes(append(splitScope(scope), "offline_access"))
+		}
+	}
+	v := url.Values{}
+	for _, p := range params {
+		v.Add(p.Key, p.Value)
+	}
+	responseType := c.ResponseType
+	if responseType == "" {
+		responseType = "code"
+	}
+	std := url.Values{
+		"response_type":   {responseType},
+		"client_id":       {c.ClientId},
+		"state":           condVal(state),
+		"scope":           condVal(scope),
+		"redirect_uri":    condVal(c.RedirectURL),
+		"access_type":     condVal(accessType),
+		"approval_prompt": condVal(c.ApprovalPrompt),
+	}
+	if len(c.Resources) > 0 {
+		std["resource"] = c.Resources
+	}
+	for k, vs := range std {
+		if vs != nil {
+			v[k] = vs
+		}
+	}
+	q := v.Encode()
+	if url_.RawQuery == "" {
+		url_.RawQuery = q
+	} else {
+		url_.RawQuery += "&" + q
+	}
+	return url_.String()
+}
+
+// hasScope reports whether scope includes s.
+func hasScope(scope, s string) bool {
+	for _, f := range splitScope(scope) {
+		if f == s {
+			return true
+		}
+	}
+	return false
+}
+
+// IncludeGrantedScopes asks Google to include the scopes the user has
+// already granted to the client in the new Token, for incremental
+// authorization.
+//
+//	url := config.WithScopes(driveScope).AuthCodeURL(state, oauth.IncludeGrantedScopes)
+var IncludeGrantedScopes = AuthParam{"include_granted_scopes", "true"}
+
+// WithScopes returns a copy of c that requests the union of c's scopes
+// and the given scopes, so that a user may be asked for more access than
+// they have already granted.
+func (c *Config) WithScopes(scopes ...string) *Config {
+	c2 := *c
+	seen := make(map[string]bool)
+	c2.Scopes = nil
+	for _, s := range append(splitScope(c.scope()), scopes...) {
+		if s != "" && !seen[s] {
+			seen[s] = true
+			c2.Scopes = append(c2.Scopes, s)
+		}
+	}
+	c2.Scope = c2.joinScopes(c2.Scopes)
+	return &c2
+}
+
+// WithRedirectURL returns a copy of c with its RedirectURL set to u. It is
+// useful for a client serving several hostnames, which must pick the
+// registered redirect URL matching each request:
+//
+//	func redirectURL(r *http.Request) string {
+//		return "https://" + r.Host + "/oauth2callback"
+//	}
+//
+//	// Sending the user to the provider:
+//	url := config.WithRedirectURL(redirectURL(r)).AuthCodeURL(state)
+//
+//	// Handling the redirect back, which arrives at the same host:
+//	t := &oauth.Transport{Config: config.WithRedirectURL(redirectURL(r))}
+//	tok, err := t.Handle(r, state)
+func (c *Config) WithRedirectURL(u string) *Config {
+	c2 := *c
+	c2.RedirectURL = u
+	return &c2
+}
+
+// scope returns the value of the scope parameter.
+func (c *Config) scope() string {
+	if len(c.Scopes) > 0 {
+		return c.joinScopes(c.Scopes)
+	}
+	return c.Scope
+}
+
+// joinScopes joins scopes with c's ScopeSeparator.
+func (c *Config) joinScopes(scopes []string) string {
+	sep := c.ScopeSeparator
+	if sep == "" {
+		sep = " "
+	}
+	return strings.Join(scopes, sep)
+}
+
+// splitScope splits a scope value separated by spaces or commas.
+func splitScope(scope string) []string {
+	return strings.FieldsFunc(scope, func(r rune) bool {
+		return r == ',' || r == ' '
+	})
+}
+
+func condVal(v string) []string {
+	if v == "" {
+		return nil
+	}
+	return []string{v}
+}
+
+// NewState returns a random, URL-safe value for the state parameter of
+// AuthCodeURL. The state protects the redirect handler against cross-site
+// request forgery: store it in the user's session before redirecting to
+// the provider, and check it with ValidateState when the user returns.
+func NewState() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// ValidateState reports whether the state received by the redirect handler
+// matches the one stored in the session. The comparison takes constant
+// time. An empty state is never valid.
+func ValidateState(got, want string) bool {
+	if want == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(got), []byte(want)) == 1
+}
+
+// NewCodeVerifier returns a random PKCE code verifier, as described in
+// RFC 7636 section 4.1.
+func NewCodeVerifier() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// CodeChallenge returns the S256 PKCE code challenge for the given
+// code verifier.
+func CodeChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// Exchange takes a code and gets access Token from the remote server.
+// On success the Token is stored on the Transport: the result is the
+// Transport's Token, with its type, scope, ID token and absolute Expiry
+// filled in from the response. On failure the Token is still returned,
+// so that callers may inspect it, but it is not stored.
+func (t *Transport) Exchange(code string) (*Token, error) {
+	return t.ExchangeContext(context.Background(), code)
+}
+
+// ExchangeContext is like Exchange, but the request to the token endpoint
+// is made with the given context.
+func (t *Transport) ExchangeContext(ctx context.Context, code string) (*Token, error) {
+	if t.Config == nil {
+		return nil, OAuthError{"Exchange", "no Config supplied"}
+	}
+	// If the transport or the cache already has a token, a copy of it
+	// is passed to `updateToken` to preserve existing refresh token.
+	// The copy replaces the Transport's Token only once it has been
+	// accepted by TokenRefreshed and the TokenCache. t.mu is not held
+	// while the token endpoint and the callbacks are called, so that
+	// requests are not held up and the callbacks may use t.
+	var tok *Token
+	t.mu.Lock()
+	if t.Token != nil {
+		tok = copyToken(t.Token)
+	}
+	t.mu.Unlock()
+	if tok == nil && t.TokenCache != nil {
+		tok, _ = t.TokenCache.Token()
+	}
+	if tok == nil {
+		tok = new(Token)
+	}
+	v := url.Values{
+		"grant_type":   {"authorization_code"},
+		"redirect_uri": {t.RedirectURL},
+		"scope":        {t.scope()},
+		"code":         {code},
+	}
+	if t.CodeVerifier != "" {
+		v.Set("code_verifier", t.CodeVerifier)
+	}
+	start := time.Now()
+	err := t.updateToken(ctx, tok, v)
+	if t.OnExchange != nil {
+		t.OnExchange(time.Since(start), err)
+	}
+	if err != nil {
+		return tok, err
+	}
+	if t.TokenCache != nil {
+		if err := t.TokenCache.PutToken(tok); err != nil {
+			return tok, err
+		}
+	}
+	t.mu.Lock()
+	t.Token = tok
+	t.mu.Unlock()
+	return tok, nil
+}
+
+// copyToken returns a copy of tok that shares none of its maps.
+func copyToken(tok *Token) *Token {
+	tok2 := *tok
+	if tok.Extra != nil {
+		tok2.Extra = make(map[string]string, len(tok.Extra))
+		for k, v := range tok.Extra {
+			tok2.Extra[k] = v
+		}
+	}
+	if tok.Raw != nil {
+		tok2.Raw = make(map[string]interface{}, len(tok.Raw))
+		for k, v := range tok.Raw {
+			tok2.Raw[k] = v
+		}
+	}
+	return &tok2
+}
+
+// RoundTrip executes a single HTTP transaction using the Transport's
+// Token as authorization headers.
+//
+// This method will attempt to renew the Token if it has expired (or will
+// expire within the Config's ExpiryDelta) and may return an error related
+// to that Token renewal before attempting the client request.
+// If the Token cannot be renewed a non-nil os.Error value will be returned.
+//
+// Requests to the token endpoint are made with the request's context.
+//
+// If the server rejects the Token as invalid with a 401 response whose
+// WWW-Authenticate header has the Bearer error "invalid_token", perhaps
+// because the local clock is behind the server's, the Token is renewed and
+// the request is retried once. Other 401 responses, such as those for
+// "insufficient_scope", are returned to the caller as they are. A request
+// with a body is retried only if the body can be sent again: either the
+// request has a GetBody function (as set by http.NewRequest for common
+// readers) or its ContentLength is known and no more than 1MB, in which
+// case the body is buffered before the first attempt. Other requests are
+// not retried, nor are those whose context was made by WithoutRetry. The
+// only safety net for a request with an unbuffered body, such as a
+// streaming upload, is that a Token with a RefreshToken is refreshed
+// before sending it if the Token will expire within the Config's
+// StreamExpiryDelta; if that refresh fails, the Token is used as it is
+// while it remains valid. The Token is renewed at most once for each
+// call, so a 401 response to the retry is returned to the caller. If the
+// Token is invalid callers should expect HTTP-level errors, as indicated
+// by the Response's StatusCode.
+//
+// When an http.Client follows a redirect to a different host, or from
+// https to http, RoundTrip sends the redirected request without the
+// Token, so that it is not revealed to a server it was not meant for.
+//
+// Whenever the server has responded, RoundTrip returns that response
+// with a nil error, as http.RoundTripper requires. In particular, if
+// renewing the Token after a 401 response fails, the 401 response is
+// returned, unread, rather than the error; a failed request to the token
+// endpoint is still reported to LogEvent.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.isClosed() {
+		return nil, ErrTransportClosed
+	}
+	if redirectedAway(req) {
+		return t.transport().RoundTrip(req)
+	}
+	ctx := req.Context()
+	// To set the Authorization header, we must make a copy of the Request
+	// so that we don't modify the Request we were given.
+	// This is required by the specification of http.RoundTripper.
+	req2 := cloneRequest(req)
+	if t.UserAgent != "" && req2.Header.Get("User-Agent") == "" {
+		req2.Header.Set("User-Agent", t.UserAgent)
+	}
+	if err := bufferBody(req2); err != nil {
+		return nil, err
+	}
+	if req2.Body != nil && req2.Body != http.NoBody && req2.GetBody == nil {
+		t.refreshEarly(ctx)
+	}
+	auth, err := t.getAuthHeader(ctx)
+	if err != nil {
+		return nil, err
+	}
+	t.setAuth(req2, auth)
+
+	// Make the HTTP request.
+	resp, err := t.transport().RoundTrip(req2)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+	if ctx.Value(noRetryKey{}) != nil {
+		t.logUnauthorized(req, false)
+		return resp, nil
+	}
+	if bearerError(resp.Header) != "invalid_token" {
+		// Renewing the Token won't help; the rejection may be
+		// for insufficient scope, or not about the Token at all.
+		t.logUnauthorized(req, false)
+		return resp, nil
+	}
+	hasBody := req2.Body != nil && req2.Body != http.NoBody
+	if hasBody && req2.GetBody == nil {
+		// The body has been consumed and cannot be sent again.
+		t.logUnauthorized(req, false)
+		return resp, nil
+	}
+	auth, err = t.refreshAuthHeader(ctx, auth)
+	if err != nil {
+		// Let the caller see the server's response.
+		t.logUnauthorized(req, false)
+		return resp, nil
+	}
+	req3 := cloneRequest(req2)
+	t.setAuth(req3, auth)
+	if hasBody {
+		if req3.Body, err = req2.GetBody(); err != nil {
+			t.logUnauthorized(req, false)
+			return resp, nil
+		}
+	}
+	t.logUnauthorized(req, true)
+	if t.On401Retry != nil {
+		t.On401Retry()
+	}
+	// Drain the rejected response so that its connection can be reused.
+	io.CopyN(ioutil.Discard, resp.Body, 4<<10)
+	resp.Body.Close()
+	return t.transport().RoundTrip(req3)
+}
+
+// setAuth adds the Authorization header value auth to r, which must be a
+// copy of the caller's request, as directed by t.TokenStyle.
+func (t *Transport) setAuth(r *http.Request, auth string) {
+	if t.TokenStyle != TokenInQuery {
+		r.Header.Set("Authorization", auth)
+	}
+	if t.TokenStyle == TokenInHeader {
+		return
+	}
+	tok := auth[strings.Index(auth, " ")+1:]
+	u := *r.URL
+	if q := u.Query(); q.Get("access_token") != "" {
+		q.Set("access_token", tok)
+		u.RawQuery = q.Encode()
+	} else if u.RawQuery == "" {
+		u.RawQuery = "access_token=" + url.QueryEscape(tok)
+	} else {
+		u.RawQuery += "&access_token=" + url.QueryEscape(tok)
+	}
+	r.URL = &u
+}
+
+// maxBufferedBody is the largest request body that RoundTrip will buffer
+// so that the request can be retried.
+const maxBufferedBody = 1 << 20
+
+// bufferBody reads the body of r into memory and sets r.GetBody, so that
+// the body can be sent again, if r has a body of known, modest length and
+// no GetBody function.
+func bufferBody(r *http.Request) error {
+	if r.Body == nil || r.Body == http.NoBody || r.GetBody != nil {
+		return nil
+	}
+	if r.ContentLength <= 0 || r.ContentLength > maxBufferedBody {
+		return nil
+	}
+	body, err := ioutil.ReadAll(r.Body)
+	r.Body.Close()
+	if err != nil {
+		return err
+	}
+	r.GetBody = func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(bytes.NewReader(body)), nil
+	}
+	r.Body, _ = r.GetBody()
+	return nil
+}
+
+// refreshEarly refreshes the Token if it has a RefreshToken and will
+// expire within the StreamExpiryDelta, for a request that cannot be
+// retried after a 401 response. A failure is left to getAuthHeader,
+// which uses the Token as long as it remains valid.
+func (t *Transport) refreshEarly(ctx context.Context) {
+	if t.TokenSource != nil || t.Config == nil {
+		return
+	}
+	d := t.StreamExpiryDelta
+	if d == 0 {
+		d = DefaultStreamExpiryDelta
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.Token != nil && t.Refreshable() && t.expiresWithin(t.now(), d) {
+		t.refresh(ctx)
+	}
+}
+
+// getAuthHeader returns the Authorization header value for the Token,
+// renewing the Token first if necessary.
+func (t *Transport) getAuthHeader(ctx context.Context) (string, error) {
+	tok, err := t.currentToken(ctx)
+	if err != nil {
+		return "", err
+	}
+	return t.authHeader(ctx, tok), nil
+}
+
+// currentToken returns a usable Token, from the TokenSource if one is
+// set and otherwise by way of validToken. It returns a copy of t.Token,
+// which a later refresh may overwrite, and the copy must not be modified.
+func (t *Transport) currentToken(ctx context.Context) (*Token, error) {
+	if t.TokenSource != nil {
+		tok, err := t.TokenSource.Token()
+		if err != nil {
+			return nil, err
+		}
+		if tok == nil || tok.AccessToken == "" {
+			return nil, OAuthError{"RoundTrip", "no access token obtained from TokenSource"}
+		}
+		return tok, nil
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if err := t.validToken(ctx); err != nil {
+		return nil, err
+	}
+	tok := *t.Token
+	return &tok, nil
+}
+
+// authHeader returns the Authorization header value for tok.
+// The scheme is the first of one set on ctx by WithAuthScheme, the
+// Config's AuthScheme, and the Token's type.
+func (t *Transport) authHeader(ctx context.Context, tok *Token) string {
+	if scheme, ok := ctx.Value(authSchemeKey{}).(string); ok {
+		return scheme + " " + tok.AccessToken
+	}
+	if t.Config != nil && t.AuthScheme != "" {
+		return t.AuthScheme + " " + tok.AccessToken
+	}
+	return tok.Type() + " " + tok.AccessToken
+}
+
+type authSchemeKey struct{}
+
+// WithAuthScheme returns a copy of ctx that makes a Transport use scheme
+// in the Authorization header of a request made with the context, in
+// place of the Config's AuthScheme and the Token's type. It lets one
+// Transport serve APIs that expect different schemes:
+//
+//	req = req.WithContext(oauth.WithAuthScheme(req.Context(), "token"))
+func WithAuthScheme(ctx context.Context, scheme string) context.Context {
+	return context.WithValue(ctx, authSchemeKey{}, scheme)
+}
+
+type noRetryKey struct{}
+
+// WithoutRetry returns a copy of ctx that makes a Transport send a
+// request made with the context only once, returning a 401 response to
+// the caller rather than renewing the Token and retrying, for requests
+// that must not be replayed. An expired Token is still renewed before
+// the request is sent.
+func WithoutRetry(ctx context.Context) context.Context {
+	return context.WithValue(ctx, noRetryKey{}, true)
+}
+
+// validToken makes sure that t.Token is set, loading it from the
+// TokenCache if necessary, and renews it if it has expired or is about to.
+// t.mu must be held.
+func (t *Transport) validToken(ctx context.Context) error {
+	if t.Token == nil {
+		if t.Config == nil {
+			return OAuthError{"RoundTrip", "no Config supplied"}
+		}
+		if t.TokenCache == nil {
+			return OAuthError{"RoundTrip", "no Token supplied"}
+		}
+		var err error
+		t.Token, err = t.TokenCache.Token()
+		if err != nil {
+			return err
+		}
+	}
+
+	// Refresh the Token if it has expired or is about to.
+	if t.expiresWithin(t.now(), t.expiryDelta()) {
+		if err := t.refresh(ctx); err != nil {
+			return err
+		}
+	}
+	if t.AccessToken == "" {
+		return errors.New("no access token obtained from refresh")
+	}
+	return nil
+}
+
+// refreshAuthHeader renews the Token after the server rejected the
+// Authorization header value rejected, and returns the new value.
+// If another request has renewed the Token in the meantime, that Token
+// is used instead.
+func (t *Transport) refreshAuthHeader(ctx context.Context, rejected string) (string, error) {
+	if t.TokenSource != nil {
+		auth, err := t.getAuthHeader(ctx)
+		if err == nil && auth == rejected {
+			return "", OAuthError{"RoundTrip", "TokenSource returned the rejected Token"}
+		}
+		return auth, err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.Token == nil {
+		return "", OAuthError{"RoundTrip", "no Token supplied"}
+	}
+	if auth := t.authHeader(ctx, t.Token); auth != rejected && !t.expiresWithin(t.now(), t.expiryDelta()) {
+		return auth, nil
+	}
+	if err := t.refresh(ctx); err != nil {
+		return "", err
+	}
+	return t.authHeader(ctx, t.Token), nil
+}
+
+func (t *Transport) expiryDelta() time.Duration {
+	switch {
+	case t.Config == nil || t.ExpiryDelta == 0:
+		return DefaultExpiryDelta
+	case t.ExpiryDelta < 0:
+		return 0
+	}
+	return t.ExpiryDelta
+}
+
+// redirectedAway reports whether req follows a redirect to another host
+// than, or from https to a less secure scheme than, the request that
+// began the chain of redirects.
+func redirectedAway(req *http.Request) bool {
+	first := req
+	for first.Response != nil && first.Response.Request != nil {
+		first = first.Response.Request
+	}
+	if first == req {
+		return false
+	}
+	return req.URL.Host != first.URL.Host ||
+		first.URL.Scheme == "https" && req.URL.Scheme != "https"
+}
+
+// cloneRequest returns a clone of the provided *http.Request.
+// The clone is a shallow copy of the struct and its Header map.
+func cloneRequest(r *http.Request) *http.Request {
+	// shallow copy of the struct
+	r2 := new(http.Request)
+	*r2 = *r
+	// deep copy of the Header
+	r2.Header = make(http.Header)
+	for k, s := range r.Header {
+		r2.Header[k] = append([]string(nil), s...)
+	}
+	return r2
+}
+
+// Refresh renews the Transport's AccessToken using its RefreshToken.
+// It is safe to call concurrently with requests made using the Transport.
+//
+// The Token is renewed even if it has not expired, so Refresh may be used
+// to obtain a fresh Token ahead of time, such as on startup. It returns an
+// error if the Transport has no Token or the Token has no RefreshToken.
+// TokenRefreshed, if set, is called with the new Token.
+func (t *Transport) Refresh() error {
+	return t.RefreshContext(context.Background())
+}
+
+// Valid reports whether the Transport has a usable Token, renewing it
+// first if it has expired (or will expire within the Config's ExpiryDelta)
+// and can be refreshed. If the Token cannot be made usable, Valid returns
+// false and an error explaining why. It is safe to call concurrently with
+// requests made using the Transport.
+func (t *Transport) Valid() (bool, error) {
+	if _, err := t.getAuthHeader(context.Background()); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// ValidAccessToken returns the access token of the Transport's Token,
+// renewing the Token first in the same way as Valid. It is for callers
+// that need the bare token string rather than an http.Client, such as
+// to pass as a credential to another protocol. It is safe to call
+// concurrently with requests made using the Transport.
+//
+// (It is not named AccessToken because that would hide the AccessToken
+// field of the embedded Token.)
+func (t *Transport) ValidAccessToken() (string, error) {
+	tok, err := t.currentToken(context.Background())
+	if err != nil {
+		return "", err
+	}
+	return tok.AccessToken, nil
+}
+
+// Verify reports whether the Transport's Token is still accepted by the
+// provider, as after loading a stored Token with SetToken. If the Config
+// has an IntrospectURL, the access token is checked there, after being
+// renewed if it has expired. Otherwise a Token with a RefreshToken is
+// refreshed; if the provider rejects the RefreshToken, as when the user
+// has revoked access, Verify returns false and the Token is cleared, as
+// described for ErrTokenRevoked. Any other Token is checked as by Valid,
+// without asking the provider. An error is returned if the check could
+// not be made.
+func (t *Transport) Verify() (bool, error) {
+	t.mu.Lock()
+	if t.Token == nil {
+		t.mu.Unlock()
+		return t.Valid()
+	}
+	refreshable := t.Refreshable()
+	t.mu.Unlock()
+
+	if t.Config != nil && t.IntrospectURL != "" {
+		access, err := t.ValidAccessToken()
+		if err != nil {
+			return false, err
+		}
+		in, err := t.Introspect(access)
+		if err != nil {
+			return false, err
+		}
+		return in.Active, nil
+	}
+	if !refreshable {
+		return t.Valid()
+	}
+	switch err := t.Refresh(); {
+	case err == nil:
+		return true, nil
+	case errors.Is(err, ErrTokenRevoked):
+		return false, nil
+	default:
+		return false, err
+	}
+}
+
+// RefreshContext is like Refresh, but the request to the token endpoint
+// is made with the given context.
+func (t *Transport) RefreshContext(ctx context.Context) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.refresh(ctx)
+}
+
+// refresh implements RefreshContext. t.mu must be held; it is released
+// while the request to the token endpoint is made. If a refresh is already
+// in progress, refresh waits for it and returns its result, so that many
+// callers finding an expired Token at once cause a single request.
+func (t *Transport) refresh(ctx context.Context) error {
+	if c := t.refreshing; c != nil {
+		t.mu.Unlock()
+		defer t.mu.Lock()
+		select {
+		case <-c.done:
+			return c.err
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	if t.Token == nil {
+		return OAuthError{"Refresh", "no existing Token"}
+	}
+	if t.RefreshToken == "" {
+		return ErrNoRefreshToken
 	}
 	if t.Config == nil {
 		return OAuthError{"Refresh", "no Config supplied"}
 	}
+	if t.revoked != nil && t.revoked.refreshToken == t.RefreshToken {
+		// Such as a Token reloaded from the TokenCache.
+		t.Token = nil
+		return t.revoked
+	}
+
+	c := &refreshCall{done: make(chan struct{})}
+	t.refreshing = c
+	start := time.Now()
+	defer func() {
+		t.refreshing = nil
+		close(c.done)
+		if t.OnRefresh != nil {
+			t.mu.Unlock()
+			t.OnRefresh(time.Since(start), c.err)
+			t.mu.Lock()
+		}
+	}()
+
+	orig := t.Token
+	tok := *orig
+	if orig.Extra != nil {
+		tok.Extra = make(map[string]string, len(orig.Extra))
+		for k, v := range orig.Extra {
+			tok.Extra[k] = v
+		}
+	}
+	t.mu.Unlock()
+	c.err = t.requestToken(ctx, &tok, url.Values{
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {tok.RefreshToken},
+	})
+	t.mu.Lock()
+	if te, ok := c.err.(*TokenError); ok && te.Code == "invalid_grant" {
+		if t.Token == orig {
+			t.Token = nil
+		}
+		t.revoked = &revokedError{tok.RefreshToken, te}
+		c.err = t.revoked
+	}
+	if c.err != nil {
+		return c.err
+	}
+	// Update the Token in place, as documented on Transport. Other
+	// refreshes wait for this one while the callbacks run without t.mu.
+	*orig = tok
+	t.mu.Unlock()
+	c.err = t.tokenRefreshed(orig)
+	if c.err == nil && t.TokenCache != nil {
+		c.err = t.TokenCache.PutToken(orig)
+	}
+	t.mu.Lock()
+	return c.err
+}
+
+// TokenWithScope uses the Transport's RefreshToken to obtain a new Token
+// limited to the given scopes, which should be a subset of those granted,
+// for least-privilege calls to other services. The Transport's own Token
+// is not replaced and TokenRefreshed is not called, except that if the
+// provider issues a new RefreshToken in place of the old one, the
+// Transport's Token and the TokenCache are updated to use it.
+func (t *Transport) TokenWithScope(scopes ...string) (*Token, error) {
+	t.mu.Lock()
+	if t.Config == nil {
+		t.mu.Unlock()
+		return nil, OAuthError{"TokenWithScope", "no Config supplied"}
+	}
+	if t.Token == nil {
+		t.mu.Unlock()
+		return nil, OAuthError{"TokenWithScope", "no existing Token"}
+	}
+	refreshToken := t.RefreshToken
+	t.mu.Unlock()
+	if refreshToken == "" {
+		return nil, ErrNoRefreshToken
+	}
 
-	err := t.updateToken(t.Token, url.Values{
+	tok := &Token{RefreshToken: refreshToken}
+	err := t.requestToken(context.Background(), tok, url.Values{
 		"grant_type":    {"refresh_token"},
-		"refresh_token": {t.RefreshToken},
+		"refresh_token": {refreshToken},
+		"scope":         {t.joinScopes(scopes)},
 	})
 	if err != nil {
-		return err
+		return nil, err
 	}
-	if t.TokenCache != nil {
-		return t.TokenCache.PutToken(t.Token)
+	if tok.RefreshToken != refreshToken {
+		t.mu.Lock()
+		primary := t.Token
+		if primary != nil && primary.RefreshToken == refreshToken {
+			primary.RefreshToken = tok.RefreshToken
+		} else {
+			primary = nil
+		}
+		t.mu.Unlock()
+		if primary != nil && t.TokenCache != nil {
+			if err := t.TokenCache.PutToken(primary); err != nil {
+				return nil, err
+			}
+		}
 	}
-	return nil
+	return tok, nil
+}
+
+// refreshCall is a refresh in progress.
+type refreshCall struct {
+	done chan struct{} // closed when the refresh is complete
+	err  error
 }
 
 // AuthenticateClient gets an access Token using the client_credentials grant
-// type.
+// type. It is like ClientCredentials but only returns the error.
 func (t *Transport) AuthenticateClient() error {
+	_, err := t.ClientCredentials()
+	return err
+}
+
+// ClientCredentials gets an access Token using the client_credentials grant
+// type and stores it on the Transport. Servers typically do not issue a
+// refresh token for this grant; call ClientCredentials again once the
+// Token has expired.
+func (t *Transport) ClientCredentials() (*Token, error) {
 	if t.Config == nil {
-		return OAuthError{"Exchange", "no Config supplied"}
+		return nil, OAuthError{"ClientCredentials", "no Config supplied"}
 	}
-	if t.Token == nil {
-		t.Token = &Token{}
+	return t.grant(context.Background(), url.Values{
+		"grant_type": {"client_credentials"},
+		"scope":      condVal(t.scope()),
+	})
+}
+
+// PasswordCredentials gets an access Token using the resource owner
+// password credentials grant type and stores it on the Transport.
+// The username and password are sent only in the body of the request
+// to the token endpoint.
+func (t *Transport) PasswordCredentials(username, password string) (*Token, error) {
+	if t.Config == nil {
+		return nil, OAuthError{"PasswordCredentials", "no Config supplied"}
 	}
-	return t.updateToken(t.Token, url.Values{"grant_type": {"client_credentials"}})
+	return t.grant(context.Background(), url.Values{
+		"grant_type": {"password"},
+		"username":   {username},
+		"password":   {password},
+		"scope":      condVal(t.scope()),
+	})
+}
+
+// grant obtains a new Token using the given parameters and, on success,
+// stores it on the Transport and in the TokenCache.
+func (t *Transport) grant(ctx context.Context, v url.Values) (*Token, error) {
+	tok := new(Token)
+	if err := t.updateToken(ctx, tok, v); err != nil {
+		return tok, err
+	}
+	return tok, t.setToken(tok)
+}
+
+// SetToken sets the Transport's Token, such as one loaded from storage
+// when a program starts, and may be called while requests are being
+// made. The TokenCache is not written. See Verify.
+func (t *Transport) SetToken(tok *Token) {
+	t.mu.Lock()
+	t.Token = tok
+	t.mu.Unlock()
+}
+
+// setToken stores tok on the Transport and in the TokenCache.
+func (t *Transport) setToken(tok *Token) error {
+	t.mu.Lock()
+	t.Token = tok
+	t.mu.Unlock()
+	if t.TokenCache != nil {
+		return t.TokenCache.PutToken(tok)
+	}
+	return nil
 }
 
 // providerAuthHeaderWorks reports whether the OAuth2 server identified by the tokenURL
@@ -395,40 +1943,549 @@ func providerAuthHeaderWorks(tokenURL string) bool {
 
 	// Assume the provider implements the spec properly
 	// otherwise. We can add more exceptions as they're
-	// discovered. We will _not_ be adding configurable hooks
-	// to this package to let users select server bugs.
+	// discovered. Users of other broken providers can set
+	// Config.AuthStyle.
 	return true
 }
 
-// updateToken mutates both tok and v.
-func (t *Transport) updateToken(tok *Token, v url.Values) error {
-	v.Set("client_id", t.ClientId)
-	bustedAuth := !providerAuthHeaderWorks(t.TokenURL)
-	if bustedAuth {
-		v.Set("client_secret", t.ClientSecret)
-	}
-	client := &http.Client{Transport: t.transport()}
-	req, err := http.NewRequest("POST", t.TokenURL, strings.NewReader(v.Encode()))
+// TokenStyle describes how a Transport sends the access token with the
+// requests it makes.
+type TokenStyle int
+
+const (
+	// TokenInHeader sends the token in the Authorization header.
+	TokenInHeader TokenStyle = iota
+
+	// TokenInQuery sends the token as the "access_token" query
+	// parameter, as some legacy APIs require. A token in a URL may be
+	// recorded in server logs, proxy logs and browser history, so use the
+	// header whenever the API allows it.
+	TokenInQuery
+
+	// TokenInHeaderAndQuery sends the token in both places.
+	TokenInHeaderAndQuery
+)
+
+// authStyle returns the AuthStyle to use with the provider.
+func (c *Config) authStyle() AuthStyle {
+	if c.AuthStyle != AuthStyleDefault {
+		return c.AuthStyle
+	}
+	if providerAuthHeaderWorks(c.TokenURL) {
+		return AuthStyleInHeader
+	}
+	return AuthStyleInParams
+}
+
+// postForm posts v to the given endpoint of the OAuth provider,
+// authenticating the client as the provider expects. It mutates v.
+func (t *Transport) postForm(ctx context.Context, endpoint string, v url.Values) (*http.Response, error) {
+	if t.ClientAuth != nil {
+		return t.postFormAuth(ctx, endpoint, v, t.ClientAuth)
+	}
+	style := t.authStyle()
+	if style != AuthStyleAutoDetect {
+		return t.postFormStyle(ctx, endpoint, v, style)
+	}
+	t.styleMu.Lock()
+	style = t.detectedStyle
+	t.styleMu.Unlock()
+	if style != AuthStyleDefault {
+		return t.postFormStyle(ctx, endpoint, v, style)
+	}
+
+	orig := make(url.Values)
+	for k, vs := range v {
+		orig[k] = vs
+	}
+	r, err := t.postFormStyle(ctx, endpoint, v, AuthStyleInParams)
+	if err != nil || !styleSettled(r) {
+		return r, err
+	}
+	style = AuthStyleInParams
+	if rejectedClient(r) {
+		r.Body.Close()
+		r, err = t.postFormStyle(ctx, endpoint, orig, AuthStyleInHeader)
+		if err != nil || !styleSettled(r) || rejectedClient(r) {
+			return r, err
+		}
+		style = AuthStyleInHeader
+	}
+	t.styleMu.Lock()
+	t.detectedStyle = style
+	t.styleMu.Unlock()
+	return r, nil
+}
+
+// postFormRetry is like postForm, but retries the request as configured
+// by MaxRetries and Backoff or RetryBackoff.
+func (t *Transport) postFormRetry(ctx context.Context, endpoint string, v url.Values) (*http.Response, error) {
+	retries := t.MaxRetries
+	if v.Get("grant_type") == "authorization_code" {
+		retries = 0
+	}
+	// Only errors from sending the request are worth retrying.
+	if err := t.checkEndpoint(endpoint); err != nil {
+		return nil, err
+	}
+	backoff := t.backoff()
+	for attempt := 0; ; attempt++ {
+		r, err := t.postForm(ctx, endpoint, v)
+		if attempt >= retries || ctx.Err() != nil || err == ErrTransportClosed {
+			return r, err
+		}
+		// Sleep as the Backoff says, unless the server says how long
+		// to wait.
+		wait := backoff.NextDelay(attempt)
+		if err == nil {
+			if r.StatusCode < 500 && r.StatusCode != http.StatusTooManyRequests {
+				return r, nil
+			}
+			if d, ok := retryAfter(r, t.now()); ok {
+				if d > t.maxRetryAfter() {
+					return r, nil
+				}
+				wait = d
+			}
+			r.Body.Close()
+		}
+		if err := sleep(ctx, wait); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (t *Transport) maxRetryAfter() time.Duration {
+	if t.MaxRetryAfter > 0 {
+		return t.MaxRetryAfter
+	}
+	return time.Minute
+}
+
+// retryAfter returns the delay given by the Retry-After header of r,
+// which may be a number of seconds or an HTTP date, relative to now.
+func retryAfter(r *http.Response, now time.Time) (time.Duration, bool) {
+	h := r.Header.Get("Retry-After")
+	if h == "" {
+		return 0, false
+	}
+	if n, err := strconv.Atoi(h); err == nil && n >= 0 {
+		return time.Duration(n) * time.Second, true
+	}
+	if t, err := http.ParseTime(h); err == nil {
+		if d := t.Sub(now); d > 0 {
+			return d, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+// styleSettled reports whether the response r shows whether the AuthStyle
+// with which the request was made works. A server error or a 429 (Too
+// Many Requests) response says nothing about it.
+func styleSettled(r *http.Response) bool {
+	return r.StatusCode < 500 && r.StatusCode != http.StatusTooManyRequests
+}
+
+// rejectedClient reports whether the response r indicates that the
+// provider did not accept the client's credentials. If the body of r is
+// examined, it is replaced so that it may be read again.
+func rejectedClient(r *http.Response) bool {
+	switch r.StatusCode {
+	case http.StatusUnauthorized:
+		return true
+	case http.StatusBadRequest:
+		body, err := ioutil.ReadAll(io.LimitReader(r.Body, 1<<20))
+		r.Body.Close()
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+		if err != nil {
+			return false
+		}
+		var b struct {
+			Code string `json:"error"`
+		}
+		return json.Unmarshal(body, &b) == nil && b.Code == "invalid_client"
+	}
+	return false
+}
+
+// postFormStyle is like postForm but authenticates the client using
+// the given AuthStyle, which must not be AuthStyleAutoDetect, trying
+// both the ClientSecret and the SecondaryClientSecret if there is one.
+func (t *Transport) postFormStyle(ctx context.Context, endpoint string, v url.Values, style AuthStyle) (*http.Response, error) {
+	if t.SecondaryClientSecret == "" || style == AuthStyleTLS {
+		return t.postFormSecret(ctx, endpoint, v, style, t.ClientSecret)
+	}
+	t.styleMu.Lock()
+	secondary := t.useSecondary
+	t.styleMu.Unlock()
+	first, second := t.ClientSecret, t.SecondaryClientSecret
+	if secondary {
+		first, second = second, first
+	}
+
+	orig := make(url.Values)
+	for k, vs := range v {
+		orig[k] = vs
+	}
+	r, err := t.postFormSecret(ctx, endpoint, v, style, first)
+	if err != nil || !rejectedClient(r) {
+		return r, err
+	}
+	r.Body.Close()
+	r, err = t.postFormSecret(ctx, endpoint, orig, style, second)
+	if err != nil || rejectedClient(r) {
+		return r, err
+	}
+	t.styleMu.Lock()
+	t.useSecondary = !secondary
+	t.styleMu.Unlock()
+	return r, nil
+}
+
+// postFormSecret is like postFormStyle but authenticates the client
+// with the given secret.
+func (t *Transport) postFormSecret(ctx context.Context, endpoint string, v url.Values, style AuthStyle, secret string) (*http.Response, error) {
+	var auth ClientAuthFunc
+	switch style {
+	case AuthStyleInParams:
+		auth = ClientAuthInParams(t.ClientId, secret)
+	case AuthStyleInHeader:
+		auth = ClientAuthInHeader(t.ClientId, secret)
+	default: // AuthStyleTLS
+		auth = func(req *http.Request, form url.Values) {
+			form.Set("client_id", t.ClientId)
+		}
+	}
+	return t.postFormAuth(ctx, endpoint, v, auth)
+}
+
+// postFormAuth is like postForm but authenticates the client using auth.
+func (t *Transport) postFormAuth(ctx context.Context, endpoint string, v url.Values, auth ClientAuthFunc) (*http.Response, error) {
+	if err := t.checkEndpoint(endpoint); err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest("POST", endpoint, nil)
 	if err != nil {
+		return nil, err
+	}
+	auth(req, v)
+	// Public clients have no secret, and some providers reject a
+	// request with empty fields, so leave them out.
+	for k, vs := range v {
+		if len(vs) == 0 || len(vs) == 1 && vs[0] == "" {
+			delete(v, k)
+		}
+	}
+	body, contentType := []byte(v.Encode()), "application/x-www-form-urlencoded"
+	if t.TokenRequestJSON && endpoint == t.TokenURL {
+		body, contentType = jsonParams(v), "application/json"
+	}
+	getBody := func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(bytes.NewReader(body)), nil
+	}
+	req.Body, _ = getBody()
+	req.GetBody = getBody
+	req.ContentLength = int64(len(body))
+	req.Header.Set("Content-Type", contentType)
+	if t.UserAgent != "" {
+		req.Header.Set("User-Agent", t.UserAgent)
+	}
+	if t.PrepareTokenRequest != nil {
+		t.PrepareTokenRequest(req)
+		req.Body, _ = getBody()
+		req.GetBody = getBody
+		req.ContentLength = int64(len(body))
+	}
+	if d := t.TokenRequestTimeout; d >= 0 {
+		if d == 0 {
+			d = DefaultTokenRequestTimeout
+		}
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, d)
+		r, err := t.tokenClient().Do(req.WithContext(ctx))
+		if err != nil {
+			cancel()
+			return nil, err
+		}
+		r.Body = &cancelBody{r.Body, cancel}
+		return r, nil
+	}
+	return t.tokenClient().Do(req.WithContext(ctx))
+}
+
+// checkEndpoint returns the error with which a request to endpoint
+// would fail before it is sent, if any.
+func (t *Transport) checkEndpoint(endpoint string) error {
+	if t.isClosed() {
+		return ErrTransportClosed
+	}
+	if err := t.checkScheme("postForm", "endpoint", endpoint); err != nil {
+		return err
+	}
+	_, err := url.Parse(endpoint)
+	return err
+}
+
+// cancelBody is a response body that cancels the request's context
+// when it is closed.
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+// jsonParams encodes v as a JSON object whose members are strings, or
+// arrays of strings for parameters with more than one value.
+func jsonParams(v url.Values) []byte {
+	m := make(map[string]interface{}, len(v))
+	for k, vs := range v {
+		if len(vs) == 1 {
+			m[k] = vs[0]
+		} else {
+			m[k] = vs
+		}
+	}
+	b, _ := json.Marshal(m)
+	return b
+}
+
+func (t *Transport) tokenClient() *http.Client {
+	if t.TokenClient != nil {
+		return t.TokenClient
+	}
+	rt := t.transport()
+	t.clientMu.Lock()
+	defer t.clientMu.Unlock()
+	if t.tokenHTTPClient == nil || !sameRoundTripper(t.tokenClientRT, rt) {
+		t.tokenHTTPClient = &http.Client{Transport: rt}
+		t.tokenClientRT = rt
+	}
+	return t.tokenHTTPClient
+}
+
+// sameRoundTripper reports whether a and b are the same RoundTripper.
+// RoundTrippers whose type cannot be compared, such as functions, are
+// never the same.
+func sameRoundTripper(a, b http.RoundTripper) bool {
+	if reflect.TypeOf(a) != reflect.TypeOf(b) || !reflect.TypeOf(a).Comparable() {
+		return false
+	}
+	return a == b
+}
+
+// updateToken mutates both tok and v.
+func (t *Transport) updateToken(ctx context.Context, tok *Token, v url.Values) error {
+	if err := t.requestToken(ctx, tok, v); err != nil {
+		return err
+	}
+	return t.tokenRefreshed(tok)
+}
+
+// requestToken is like updateToken but does not call TokenRefreshed.
+func (t *Transport) requestToken(ctx context.Context, tok *Token, v url.Values) error {
+	if _, ok := v["resource"]; !ok && len(t.Resources) > 0 {
+		v["resource"] = t.Resources
+	}
+	for k, vs := range t.TokenParams {
+		if _, ok := v[k]; !ok {
+			v[k] = vs
+		}
+	}
+	if err := t.fetchToken(ctx, tok, v); err != nil {
+		t.logEvent(EventTokenError, map[string]interface{}{
+			"grant_type": v.Get("grant_type"),
+			"error":      err.Error(),
+		})
 		return err
 	}
-	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
-	if !bustedAuth {
-		req.SetBasicAuth(t.ClientId, t.ClientSecret)
+	fields := map[string]interface{}{
+		"grant_type":   v.Get("grant_type"),
+		"access_token": fingerprint(tok.AccessToken),
+		"expiry":       tok.Expiry,
+	}
+	if tok.RefreshToken != "" {
+		fields["refresh_token"] = fingerprint(tok.RefreshToken)
 	}
-	r, err := client.Do(req)
+	t.logEvent(EventToken, fields)
+	return nil
+}
+
+// fetchToken requests a Token from the token endpoint with the
+// parameters v and stores the response in tok.
+func (t *Transport) fetchToken(ctx context.Context, tok *Token, v url.Values) error {
+	r, err := t.postFormRetry(ctx, t.TokenURL, v)
 	if err != nil {
 		return err
 	}
 	defer r.Body.Close()
 	if r.StatusCode != 200 {
-		return OAuthError{"updateToken", "Unexpected HTTP status " + r.Status}
+		return parseTokenError(r)
+	}
+	if err := parseToken(tok, r, t.now()); err != nil {
+		return err
+	}
+	if tok.Expiry.IsZero() && t.ExpiryFromJWT {
+		if exp, ok := jwtExpiry(tok.AccessToken); ok {
+			tok.Expiry = exp
+		}
+	}
+	return nil
+}
+
+// jwtExpiry returns the time given by the "exp" claim of s, if s looks
+// like a JWT and has one.
+func jwtExpiry(s string) (time.Time, bool) {
+	parts := strings.Split(s, ".")
+	if len(parts) != 3 {
+		return time.Time{}, false
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return time.Time{}, false
+	}
+	var claims struct {
+		Exp json.Number `json:"exp"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return time.Time{}, false
+	}
+	exp, err := claims.Exp.Float64()
+	if err != nil || exp <= 0 {
+		return time.Time{}, false
+	}
+	return time.Unix(int64(exp), 0), true
+}
+
+func (t *Transport) tokenRefreshed(tok *Token) error {
+	if t.TokenRefreshed != nil {
+		return t.TokenRefreshed(tok)
+	}
+	return nil
+}
+
+// parseTokenError returns the error described by the unsuccessful token
+// endpoint response r.
+func parseTokenError(r *http.Response) error {
+	body, err := ioutil.ReadAll(io.LimitReader(r.Body, 1<<20))
+	if err != nil {
+		return err
 	}
 	var b struct {
-		Access    string `json:"access_token"`
-		Refresh   string `json:"refresh_token"`
-		ExpiresIn int64  `json:"expires_in"` // seconds
-		Id        string `json:"id_token"`
+		Code        string `json:"error"`
+		Description string `json:"error_description"`
+		URI         string `json:"error_uri"`
+	}
+	content, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch content {
+	case "application/x-www-form-urlencoded", "text/plain":
+		vals, err := url.ParseQuery(string(body))
+		if err == nil {
+			b.Code = vals.Get("error")
+			b.Description = vals.Get("error_description")
+			b.URI = vals.Get("error_uri")
+		}
+	default:
+		json.Unmarshal(body, &b)
+	}
+	// A response without an error code, such as an HTML page from a
+	// gateway, still gives a TokenError, for its status and headers.
+	return &TokenError{
+		Code:        b.Code,
+		Description: b.Description,
+		URI:         b.URI,
+		StatusCode:  r.StatusCode,
+		Header:      errorHeader(r.Header),
+	}
+}
+
+// expiresIn is the expires_in field of a JSON token response. Some
+// providers send it as a string rather than a number, so both are accepted.
+type expiresIn int64
+
+func (e *expiresIn) UnmarshalJSON(b []byte) error {
+	if string(b) == "null" {
+		return nil
+	}
+	var n json.Number
+	if b[0] == '"' {
+		var s string
+		if err := json.Unmarshal(b, &s); err != nil {
+			return err
+		}
+		if s == "" {
+			return nil
+		}
+		n = json.Number(s)
+	} else if err := json.Unmarshal(b, &n); err != nil {
+		return err
+	}
+	i, err := n.Int64()
+	if err != nil {
+		return err
+	}
+	*e = expiresIn(i)
+	return nil
+}
+
+// expires is like expiresIn, for the nonstandard expires field, but a
+// value that is not a number is ignored rather than rejected.
+type expires int64
+
+func (e *expires) UnmarshalJSON(b []byte) error {
+	var n expiresIn
+	if n.UnmarshalJSON(b) == nil {
+		*e = expires(n)
+	}
+	return nil
+}
+
+// expiresAt is the absolute expires_at field that some providers send
+// in place of expires_in, as seconds since the Unix epoch or as an
+// RFC 3339 time. A value in neither form is ignored.
+type expiresAt time.Time
+
+func (e *expiresAt) UnmarshalJSON(b []byte) error {
+	var s string
+	if json.Unmarshal(b, &s) != nil {
+		s = string(b)
+	}
+	*e = expiresAt(parseExpiresAt(s))
+	return nil
+}
+
+func parseExpiresAt(s string) time.Time {
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil && n > 0 {
+		return time.Unix(n, 0)
+	}
+	t, _ := time.Parse(time.RFC3339, s)
+	return t
+}
+
+// parseToken updates tok from the successful token endpoint response r,
+// which was received at time now. A response with an error field is
+// reported as a *TokenError despite its status, since some providers
+// report failures with a 200 status.
+func parseToken(tok *Token, r *http.Response, now time.Time) error {
+	var b struct {
+		Error       string `json:"error"`
+		Description string `json:"error_description"`
+		URI         string `json:"error_uri"`
+
+		Access    string    `json:"access_token"`
+		Type      string    `json:"token_type"`
+		Refresh   string    `json:"refresh_token"`
+		ExpiresIn expiresIn `json:"expires_in"` // seconds
+		Expires   expires   `json:"expires"`    // seconds, for Facebook
+		ExpiresAt expiresAt `json:"expires_at"`
+		Id        string    `json:"id_token"`
+		Scope     string    `json:"scope"`
 	}
 
 	body, err := ioutil.ReadAll(io.LimitReader(r.Body, 1<<20))
@@ -436,6 +2493,7 @@ func (t *Transport) updateToken(tok *Token, v url.Values) error {
 		return err
 	}
 
+	var raw map[string]interface{}
 	content, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
 	switch content {
 	case "application/x-www-form-urlencoded", "text/plain":
@@ -445,26 +2503,63 @@ func (t *Transport) updateToken(tok *Token, v url.Values) error {
 		}
 
 		b.Access = vals.Get("access_token")
+		b.Type = vals.Get("token_type")
 		b.Refresh = vals.Get("refresh_token")
-		b.ExpiresIn, _ = strconv.ParseInt(vals.Get("expires_in"), 10, 64)
+		n, _ := strconv.ParseInt(vals.Get("expires_in"), 10, 64)
+		b.ExpiresIn = expiresIn(n)
+		n, _ = strconv.ParseInt(vals.Get("expires"), 10, 64)
+		b.Expires = expires(n)
+		b.ExpiresAt = expiresAt(parseExpiresAt(vals.Get("expires_at")))
 		b.Id = vals.Get("id_token")
+		b.Scope = vals.Get("scope")
+		b.Error = vals.Get("error")
+		b.Description = vals.Get("error_description")
+		b.URI = vals.Get("error_uri")
+		raw = make(map[string]interface{}, len(vals))
+		for k := range vals {
+			raw[k] = vals.Get(k)
+		}
 	default:
 		if err = json.Unmarshal(body, &b); err != nil {
 			return fmt.Errorf("got bad response from server: %q", body)
 		}
+		json.Unmarshal(body, &raw)
+	}
+	if b.Error != "" {
+		return &TokenError{
+			Code:        b.Error,
+			Description: b.Description,
+			URI:         b.URI,
+			StatusCode:  r.StatusCode,
+			Header:      errorHeader(r.Header),
+		}
 	}
 	if b.Access == "" {
 		return errors.New("received empty access token from authorization server")
 	}
 	tok.AccessToken = b.Access
+	tok.TokenType = b.Type
+	tok.Raw = raw
 	// Don't overwrite `RefreshToken` with an empty value
 	if b.Refresh != "" {
 		tok.RefreshToken = b.Refresh
 	}
+	// A response without a scope grants the scope requested, which for
+	// a refresh is that of the original Token.
+	if b.Scope != "" {
+		tok.Scope = b.Scope
+	}
 	if b.ExpiresIn == 0 {
+		// Facebook's legacy endpoint uses "expires".
+		b.ExpiresIn = expiresIn(b.Expires)
+	}
+	switch {
+	case b.ExpiresIn != 0:
+		tok.Expiry = now.Add(time.Duration(b.ExpiresIn) * time.Second)
+	case !time.Time(b.ExpiresAt).IsZero():
+		tok.Expiry = time.Time(b.ExpiresAt)
+	default:
 		tok.Expiry = time.Time{}
-	} else {
-		tok.Expiry = time.Now().Add(time.Duration(b.ExpiresIn) * time.Second)
 	}
 	if b.Id != "" {
 		if tok.Extra == nil {
@@ -0,0 +1,273 @@
+// Copyright 2014 The goauth2 Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package oauthtest provides a fake OAuth 2.0 provider for testing code
+// that uses the oauth package.
+//
+// A Server implements an authorization endpoint, which approves every
+// request, and a token endpoint supporting the authorization_code and
+// refresh_token grants:
+//
+//	s := oauthtest.NewServer()
+//	defer s.Close()
+//	t := &oauth.Transport{Config: s.Config()}
+//	code, _, err := s.Authorize(t.AuthCodeURL("state"))
+//	...
+//	tok, err := t.Exchange(code)
+package oauthtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"code.google.com/p/goauth2/oauth"
+)
+
+// Server is a fake OAuth 2.0 provider. Its exported fields control the
+// responses to later requests and may be changed between requests, but
+// not while requests are being made.
+type Server struct {
+	*httptest.Server
+
+	// ClientId and ClientSecret are the credentials the Server expects
+	// from clients. ClientSecret may be empty for a public client.
+	ClientId     string
+	ClientSecret string
+
+	// ExpiresIn is the lifetime of the access tokens issued. If zero,
+	// no expires_in is sent.
+	ExpiresIn time.Duration
+
+	// RotateRefreshTokens makes the Server issue a new refresh token,
+	// and invalidate the old one, on every refresh.
+	RotateRefreshTokens bool
+
+	// Error, if set, is the error code, such as "temporarily_unavailable",
+	// with which the token endpoint rejects every request.
+	Error string
+
+	// Extra holds additional fields to include in every token response,
+	// such as "scope" or "id_token".
+	Extra map[string]interface{}
+
+	mu       sync.Mutex
+	n        int
+	codes    map[string]grant // unused authorization codes
+	refresh  map[string]grant // valid refresh tokens
+	requests int
+}
+
+// grant records what an authorization code or refresh token was issued for.
+type grant struct {
+	redirectURL string
+	challenge   string
+	scope       string
+}
+
+// NewServer starts and returns a new Server with the client id "client"
+// and the client secret "secret". The caller should call Close when
+// finished, to shut it down.
+func NewServer() *Server {
+	s := &Server{
+		ClientId:     "client",
+		ClientSecret: "secret",
+		codes:        make(map[string]grant),
+		refresh:      make(map[string]grant),
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/auth", s.handleAuth)
+	mux.HandleFunc("/token", s.handleToken)
+	s.Server = httptest.NewServer(mux)
+	return s
+}
+
+// Config returns a Config for a client of the Server.
+func (s *Server) Config() *oauth.Config {
+	return &oauth.Config{
+		ClientId:     s.ClientId,
+		ClientSecret: s.ClientSecret,
+		AuthURL:      s.URL + "/auth",
+		TokenURL:     s.URL + "/token",
+		RedirectURL:  "http://127.0.0.1/callback",
+		AuthStyle:    oauth.AuthStyleInHeader,
+	}
+}
+
+// Authorize makes the request to the authorization URL authURL that a
+// user's browser would, and returns the code and state passed to the
+// redirect URL. The Server grants every valid request.
+func (s *Server) Authorize(authURL string) (code, state string, err error) {
+	client := &http.Client{
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	resp, err := client.Get(authURL)
+	if err != nil {
+		return "", "", err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusFound {
+		return "", "", fmt.Errorf("oauthtest: authorization request failed: %s", resp.Status)
+	}
+	loc, err := resp.Location()
+	if err != nil {
+		return "", "", err
+	}
+	q := loc.Query()
+	if e := q.Get("error"); e != "" {
+		return "", "", fmt.Errorf("oauthtest: authorization request failed: %s", e)
+	}
+	return q.Get("code"), q.Get("state"), nil
+}
+
+// Revoke invalidates the refresh token, as a user revoking the client's
+// access would. Later attempts to use it fail with "invalid_grant".
+func (s *Server) Revoke(refreshToken string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.refresh, refreshToken)
+}
+
+// TokenRequests returns the number of requests made to the token endpoint.
+func (s *Server) TokenRequests() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests
+}
+
+func (s *Server) handleAuth(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	redirect, err := url.Parse(q.Get("redirect_uri"))
+	if err != nil || !redirect.IsAbs() {
+		http.Error(w, "missing or invalid redirect_uri", http.StatusBadRequest)
+		return
+	}
+	v := redirect.Query()
+	if st := q.Get("state"); st != "" {
+		v.Set("state", st)
+	}
+	switch {
+	case q.Get("response_type") != "code":
+		v.Set("error", "unsupported_response_type")
+	case q.Get("client_id") != s.ClientId:
+		v.Set("error", "unauthorized_client")
+	case q.Get("code_challenge") != "" && q.Get("code_challenge_method") != "S256":
+		v.Set("error", "invalid_request")
+	default:
+		s.mu.Lock()
+		code := s.newToken("code")
+		s.codes[code] = grant{
+			redirectURL: redirect.String(),
+			challenge:   q.Get("code_challenge"),
+			scope:       q.Get("scope"),
+		}
+		s.mu.Unlock()
+		v.Set("code", code)
+	}
+	redirect.RawQuery = v.Encode()
+	http.Redirect(w, r, redirect.String(), http.StatusFound)
+}
+
+func (s *Server) handleToken(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests++
+
+	if r.Method != "POST" {
+		tokenError(w, http.StatusMethodNotAllowed, "invalid_request")
+		return
+	}
+	if s.Error != "" {
+		tokenError(w, http.StatusBadRequest, s.Error)
+		return
+	}
+	id, secret, ok := r.BasicAuth()
+	if ok {
+		id, _ = url.QueryUnescape(id)
+		secret, _ = url.QueryUnescape(secret)
+	} else {
+		id, secret = r.PostFormValue("client_id"), r.PostFormValue("client_secret")
+	}
+	if id != s.ClientId || secret != s.ClientSecret {
+		tokenError(w, http.StatusUnauthorized, "invalid_client")
+		return
+	}
+
+	var g grant
+	switch r.PostFormValue("grant_type") {
+	case "authorization_code":
+		code := r.PostFormValue("code")
+		g, ok = s.codes[code]
+		delete(s.codes, code)
+		if !ok || r.PostFormValue("redirect_uri") != g.redirectURL {
+			tokenError(w, http.StatusBadRequest, "invalid_grant")
+			return
+		}
+		if g.challenge != "" && oauth.CodeChallenge(r.PostFormValue("code_verifier")) != g.challenge {
+			tokenError(w, http.StatusBadRequest, "invalid_grant")
+			return
+		}
+	case "refresh_token":
+		rt := r.PostFormValue("refresh_token")
+		if g, ok = s.refresh[rt]; !ok {
+			tokenError(w, http.StatusBadRequest, "invalid_grant")
+			return
+		}
+		if !s.RotateRefreshTokens {
+			s.writeToken(w, g, "")
+			return
+		}
+		delete(s.refresh, rt)
+	default:
+		tokenError(w, http.StatusBadRequest, "unsupported_grant_type")
+		return
+	}
+	rt := s.newToken("refresh")
+	s.refresh[rt] = g
+	s.writeToken(w, g, rt)
+}
+
+// writeToken writes a token response for a new access token, including
+// the refresh token rt if it is not empty. s.mu must be held.
+func (s *Server) writeToken(w http.ResponseWriter, g grant, rt string) {
+	resp := map[string]interface{}{
+		"access_token": s.newToken("access"),
+		"token_type":   "Bearer",
+	}
+	if rt != "" {
+		resp["refresh_token"] = rt
+	}
+	if s.ExpiresIn > 0 {
+		resp["expires_in"] = int64(s.ExpiresIn / time.Second)
+	}
+	if g.scope != "" {
+		resp["scope"] = g.scope
+	}
+	for k, v := range s.Extra {
+		resp[k] = v
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// newToken returns a new unique token with the given prefix.
+// s.mu must be held.
+func (s *Server) newToken(prefix string) string {
+	s.n++
+	return prefix + "-" + strconv.Itoa(s.n)
+}
+
+func tokenError(w http.ResponseWriter, status int, code string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": code})
+}
@@ -0,0 +1,99 @@
+// Copyright 2014 The goauth2 Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package oauthtest
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"code.google.com/p/goauth2/oauth"
+)
+
+func TestServer(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	s.ExpiresIn = time.Hour
+	s.RotateRefreshTokens = true
+
+	verifier, err := oauth.NewCodeVerifier()
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := s.Config()
+	config.Scope = "email"
+	transport := &oauth.Transport{Config: config, CodeVerifier: verifier}
+	code, state, err := s.Authorize(transport.AuthCodeURL("st4t3"))
+	if err != nil {
+		t.Fatalf("Authorize: %v", err)
+	}
+	if state != "st4t3" {
+		t.Errorf("state = %q, want st4t3", state)
+	}
+	tok, err := transport.Exchange(code)
+	if err != nil {
+		t.Fatalf("Exchange: %v", err)
+	}
+	if tok.AccessToken == "" || tok.RefreshToken == "" || tok.Scope != "email" {
+		t.Errorf("Exchange = %+v", tok)
+	}
+	if d := tok.Expiry.Sub(time.Now()); d < 59*time.Minute || d > time.Hour {
+		t.Errorf("Expiry in %v, want 1h", d)
+	}
+
+	// A code may be used only once.
+	if _, err := transport.Exchange(code); err == nil {
+		t.Errorf("second Exchange of code succeeded")
+	}
+	transport.Token = tok
+
+	old := *tok
+	if err := transport.Refresh(); err != nil {
+		t.Fatalf("Refresh: %v", err)
+	}
+	if transport.AccessToken == old.AccessToken || transport.RefreshToken == old.RefreshToken {
+		t.Errorf("Refresh did not issue new tokens: %+v", transport.Token)
+	}
+
+	s.Revoke(transport.RefreshToken)
+	if err := transport.Refresh(); !errors.Is(err, oauth.ErrTokenRevoked) {
+		t.Errorf("Refresh of revoked token: err = %v, want ErrTokenRevoked", err)
+	}
+	if n := s.TokenRequests(); n != 4 {
+		t.Errorf("TokenRequests = %d, want 4", n)
+	}
+}
+
+func TestServerErrors(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+
+	transport := &oauth.Transport{Config: s.Config()}
+	transport.CodeVerifier = "v3r1f13r"
+	code, _, err := s.Authorize(transport.AuthCodeURL(""))
+	if err != nil {
+		t.Fatalf("Authorize: %v", err)
+	}
+	transport.CodeVerifier = "wrong"
+	if _, err := transport.Exchange(code); err == nil {
+		t.Errorf("Exchange with wrong code verifier succeeded")
+	}
+
+	s.Error = "temporarily_unavailable"
+	_, err = transport.Exchange("c0d3")
+	if te, ok := err.(*oauth.TokenError); !ok || te.Code != s.Error {
+		t.Errorf("Exchange with Error set: err = %v, want %s", err, s.Error)
+	}
+
+	config := s.Config()
+	config.ClientSecret = "wrong"
+	transport = &oauth.Transport{Config: config}
+	s.Error = ""
+	code, _, _ = s.Authorize(transport.AuthCodeURL(""))
+	_, err = transport.Exchange(code)
+	if te, ok := err.(*oauth.TokenError); !ok || te.Code != "invalid_client" {
+		t.Errorf("Exchange with wrong secret: err = %v, want invalid_client", err)
+	}
+}
@@ -0,0 +1,100 @@
+// Copyright 2014 The goauth2 Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package oauth
+
+import (
+	"context"
+	"net/url"
+)
+
+// Revoke revokes the Transport's access token at the Config's RevokeURL,
+// as described in RFC 7009. On success the Transport's Token is cleared.
+func (t *Transport) Revoke() error {
+	return t.revoke("access_token")
+}
+
+// RevokeRefreshToken is like Revoke but revokes the refresh token.
+// Servers typically also revoke the access tokens that were issued
+// using it.
+func (t *Transport) RevokeRefreshToken() error {
+	return t.revoke("refresh_token")
+}
+
+func (t *Transport) revoke(hint string) error {
+	if t.Config == nil {
+		return OAuthError{"Revoke", "no Config supplied"}
+	}
+	if t.RevokeURL == "" {
+		return OAuthError{"Revoke", "no RevokeURL supplied"}
+	}
+	t.mu.Lock()
+	tok := t.Token
+	t.mu.Unlock()
+	if tok == nil {
+		return OAuthError{"Revoke", "no existing Token"}
+	}
+	token := tok.AccessToken
+	if hint == "refresh_token" {
+		token = tok.RefreshToken
+	}
+	if token == "" {
+		return OAuthError{"Revoke", "no " + hint + " to revoke"}
+	}
+	r, err := t.postForm(context.Background(), t.RevokeURL, url.Values{
+		"token":           {token},
+		"token_type_hint": {hint},
+	})
+	if err != nil {
+		return err
+	}
+	defer r.Body.Close()
+	if r.StatusCode != 200 {
+		return parseTokenError(r)
+	}
+	// Leave a Token set by another goroutine in the meantime alone.
+	t.mu.Lock()
+	if t.Token == tok {
+		t.Token = nil
+	}
+	t.mu.Unlock()
+	return nil
+}
+
+// Reauthorize prepares for the user to authorize the client afresh, as
+// for a "reconnect account" button. It revokes the Transport's Token at
+// the RevokeURL, if one is configured, clears the Token, and returns an
+// authorization URL, as returned by BuildAuthCodeURL, that asks the
+// provider to prompt the user for consent again.
+//
+// If revoking the Token fails, the Token is cleared anyway and
+// Reauthorize returns the URL together with the error, which the caller
+// may log and otherwise ignore. As with ErrTokenRevoked, a Token in the
+// TokenCache is not removed; it is replaced when the new code is
+// exchanged.
+func (t *Transport) Reauthorize(state string, params ...AuthParam) (string, error) {
+	params = append(params[:len(params):len(params)], Prompt("consent"))
+	u, err := t.BuildAuthCodeURL(state, params...)
+	if err != nil {
+		return "", err
+	}
+	// Revoking the refresh token revokes the access tokens issued
+	// with it too.
+	hint := ""
+	t.mu.Lock()
+	if t.Token != nil {
+		hint = "access_token"
+		if t.Refreshable() {
+			hint = "refresh_token"
+		}
+	}
+	t.mu.Unlock()
+	if hint != "" && t.RevokeURL != "" {
+		err = t.revoke(hint)
+	}
+	t.mu.Lock()
+	t.Token = nil
+	t.mu.Unlock()
+	return u, err
+}
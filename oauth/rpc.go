@@ -0,0 +1,34 @@
+// Copyright 2014 The goauth2 Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package oauth
+
+import "context"
+
+// GetRequestMetadata returns the Authorization header for a request made
+// over a protocol other than HTTP, renewing the Token with ctx if it has
+// expired. Together with RequireTransportSecurity it lets a Transport be
+// used as gRPC per-RPC credentials:
+//
+//	conn, err := grpc.Dial(addr,
+//		grpc.WithTransportCredentials(creds),
+//		grpc.WithPerRPCCredentials(transport))
+//
+// The uri arguments are ignored.
+func (t *Transport) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	auth, err := t.getAuthHeader(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]string{"authorization": auth}, nil
+}
+
+// RequireTransportSecurity reports that the Token must only be sent over
+// an encrypted connection. It always returns true.
+func (t *Transport) RequireTransportSecurity() bool {
+	return true
+}
@@ -0,0 +1,57 @@
+// Copyright 2014 The goauth2 Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package oauth
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestGetRequestMetadata(t *testing.T) {
+	hang := make(chan bool)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("refresh_token") == "slow" {
+			<-hang
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"access_token":"token2","expires_in":3600}`)
+	}))
+	defer server.Close()
+	defer close(hang)
+
+	transport := &Transport{
+		Config: &Config{TokenURL: server.URL + "/token"},
+		Token:  &Token{AccessToken: "token1", RefreshToken: "refreshtoken1", Expiry: time.Now().Add(-time.Minute)},
+	}
+	md, err := transport.GetRequestMetadata(context.Background(), "https://api.example.com/Service")
+	if err != nil {
+		t.Fatalf("GetRequestMetadata: %v", err)
+	}
+	if want := map[string]string{"authorization": "Bearer token2"}; !reflect.DeepEqual(md, want) {
+		t.Errorf("GetRequestMetadata = %v, want %v", md, want)
+	}
+	if !transport.RequireTransportSecurity() {
+		t.Errorf("RequireTransportSecurity = false")
+	}
+
+	// A cancelled context fails, whether or not the Token needs renewing.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if md, err := transport.GetRequestMetadata(ctx); err != context.Canceled {
+		t.Errorf("GetRequestMetadata with cancelled context = %v, %v; want context.Canceled", md, err)
+	}
+	transport.Token = &Token{AccessToken: "token1", RefreshToken: "slow", Expiry: time.Now().Add(-time.Minute)}
+	ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if md, err := transport.GetRequestMetadata(ctx); err == nil {
+		t.Errorf("GetRequestMetadata with hung token endpoint = %v, want error", md)
+	}
+}
@@ -0,0 +1,72 @@
+// Copyright 2014 The goauth2 Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package oauth
+
+import (
+	"context"
+	"net/url"
+)
+
+// Token type identifiers for TokenExchange, from RFC 8693 section 3.
+const (
+	TokenTypeAccessToken  = "urn:ietf:params:oauth:token-type:access_token"
+	TokenTypeRefreshToken = "urn:ietf:params:oauth:token-type:refresh_token"
+	TokenTypeIdToken      = "urn:ietf:params:oauth:token-type:id_token"
+	TokenTypeJWT          = "urn:ietf:params:oauth:token-type:jwt"
+)
+
+// TokenExchange is a token exchange request, as described in RFC 8693.
+// SubjectToken and SubjectTokenType are required.
+type TokenExchange struct {
+	SubjectToken     string
+	SubjectTokenType string
+
+	// ActorToken represents the party acting on behalf of the subject,
+	// for delegation. ActorTokenType is required if it is set.
+	ActorToken     string
+	ActorTokenType string
+
+	Audience           string // Optional; the service the Token is for.
+	RequestedTokenType string // Optional; the type of Token wanted.
+}
+
+// ExchangeToken gets a Token for the subject of x using the token exchange
+// grant type, and stores it on the Transport. The type of the issued
+// Token is stored in its Extra map as "issued_token_type".
+func (t *Transport) ExchangeToken(x *TokenExchange) (*Token, error) {
+	if t.Config == nil {
+		return nil, OAuthError{"ExchangeToken", "no Config supplied"}
+	}
+	if x.SubjectToken == "" || x.SubjectTokenType == "" {
+		return nil, OAuthError{"ExchangeToken", "no subject token supplied"}
+	}
+	if x.ActorToken != "" && x.ActorTokenType == "" {
+		return nil, OAuthError{"ExchangeToken", "no actor token type supplied"}
+	}
+	v := url.Values{
+		"grant_type":           {"urn:ietf:params:oauth:grant-type:token-exchange"},
+		"subject_token":        {x.SubjectToken},
+		"subject_token_type":   {x.SubjectTokenType},
+		"actor_token":          condVal(x.ActorToken),
+		"actor_token_type":     condVal(x.ActorTokenType),
+		"audience":             condVal(x.Audience),
+		"requested_token_type": condVal(x.RequestedTokenType),
+		"scope":                condVal(t.scope()),
+	}
+	tok := new(Token)
+	if err := t.requestToken(context.Background(), tok, v); err != nil {
+		return nil, err
+	}
+	if typ, ok := tok.Raw["issued_token_type"].(string); ok {
+		if tok.Extra == nil {
+			tok.Extra = make(map[string]string)
+		}
+		tok.Extra["issued_token_type"] = typ
+	}
+	if err := t.tokenRefreshed(tok); err != nil {
+		return tok, err
+	}
+	return tok, t.setToken(tok)
+}
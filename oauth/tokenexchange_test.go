@@ -0,0 +1,69 @@
+// Copyright 2014 The goauth2 Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package oauth
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func TestExchangeToken(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		want := url.Values{
+			"grant_type":           {"urn:ietf:params:oauth:grant-type:token-exchange"},
+			"subject_token":        {"subj3ct"},
+			"subject_token_type":   {TokenTypeAccessToken},
+			"actor_token":          {"act0r"},
+			"actor_token_type":     {TokenTypeJWT},
+			"audience":             {"https://backend.example.com"},
+			"requested_token_type": {TokenTypeAccessToken},
+			"client_id":            {"cl13nt1d"},
+		}
+		if !reflect.DeepEqual(r.PostForm, want) {
+			t.Errorf("PostForm = %v, want %v", r.PostForm, want)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{
+			"access_token":"token1",
+			"issued_token_type":"urn:ietf:params:oauth:token-type:access_token",
+			"token_type":"Bearer",
+			"expires_in":60
+		}`)
+	}))
+	defer server.Close()
+
+	transport := &Transport{Config: &Config{ClientId: "cl13nt1d", TokenURL: server.URL + "/token"}}
+	tok, err := transport.ExchangeToken(&TokenExchange{
+		SubjectToken:       "subj3ct",
+		SubjectTokenType:   TokenTypeAccessToken,
+		ActorToken:         "act0r",
+		ActorTokenType:     TokenTypeJWT,
+		Audience:           "https://backend.example.com",
+		RequestedTokenType: TokenTypeAccessToken,
+	})
+	if err != nil {
+		t.Fatalf("ExchangeToken: %v", err)
+	}
+	if g, w := tok.Extra["issued_token_type"], TokenTypeAccessToken; g != w {
+		t.Errorf("issued_token_type = %q, want %q", g, w)
+	}
+	if transport.Token != tok || tok.AccessToken != "token1" || tok.Expiry.IsZero() {
+		t.Errorf("Transport Token = %+v, want %+v", transport.Token, tok)
+	}
+
+	for _, x := range []*TokenExchange{
+		{SubjectToken: "subj3ct"},
+		{SubjectToken: "subj3ct", SubjectTokenType: TokenTypeAccessToken, ActorToken: "act0r"},
+	} {
+		if _, err := transport.ExchangeToken(x); err == nil {
+			t.Errorf("ExchangeToken(%+v) succeeded, want error", x)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+// Copyright 2014 The goauth2 Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package oauth
+
+import (
+	"context"
+	"sync"
+)
+
+// TokenSource supplies Tokens. The Cache interface includes TokenSource,
+// so any Cache may be used as one.
+type TokenSource interface {
+	// Token returns a Token. It must be safe for concurrent use.
+	Token() (*Token, error)
+}
+
+// StaticTokenSource returns a TokenSource that always returns tok.
+// It is useful for testing and for Tokens that never expire.
+func StaticTokenSource(tok *Token) TokenSource {
+	return staticTokenSource{tok}
+}
+
+type staticTokenSource struct {
+	tok *Token
+}
+
+func (s staticTokenSource) Token() (*Token, error) {
+	return s.tok, nil
+}
+
+// ReuseTokenSource returns a TokenSource that returns tok until it
+// expires, then gets a new Token from src and returns that until it
+// expires, and so on. tok may be nil, in which case the first call gets
+// a Token from src.
+func ReuseTokenSource(tok *Token, src TokenSource) TokenSource {
+	// Don't wrap a reuseTokenSource in itself.
+	if rs, ok := src.(*reuseTokenSource); ok {
+		if tok == nil {
+			return rs
+		}
+		src = rs.src
+	}
+	return &reuseTokenSource{tok: tok, src: src}
+}
+
+type reuseTokenSource struct {
+	mu  sync.Mutex // guards tok
+	tok *Token
+	src TokenSource
+}
+
+func (s *reuseTokenSource) Token() (*Token, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.tok != nil && !s.tok.Expired() {
+		return s.tok, nil
+	}
+	tok, err := s.src.Token()
+	if err != nil {
+		return nil, err
+	}
+	s.tok = tok
+	return tok, nil
+}
+
+// Source returns a TokenSource that supplies the Transport's Token,
+// loading it from the TokenCache and renewing it as RoundTrip would.
+// The returned Tokens are copies, which are not changed by later
+// renewals. If the Transport has a TokenSource, it is returned.
+func (t *Transport) Source() TokenSource {
+	if t.TokenSource != nil {
+		return t.TokenSource
+	}
+	return transportSource{t}
+}
+
+type transportSource struct {
+	t *Transport
+}
+
+func (s transportSource) Token() (*Token, error) {
+	s.t.mu.Lock()
+	defer s.t.mu.Unlock()
+	if err := s.t.validToken(context.Background()); err != nil {
+		return nil, err
+	}
+	tok := *s.t.Token
+	return &tok, nil
+}
@@ -0,0 +1,116 @@
+// Copyright 2014 The goauth2 Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package oauth
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// countingSource is a TokenSource that returns a new Token, valid for
+// an hour, on each call.
+type countingSource struct {
+	calls int
+	err   error
+}
+
+func (s *countingSource) Token() (*Token, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	s.calls++
+	return &Token{
+		AccessToken: "token" + strconv.Itoa(s.calls),
+		Expiry:      time.Now().Add(time.Hour),
+	}, nil
+}
+
+func TestReuseTokenSource(t *testing.T) {
+	src := &countingSource{}
+	ts := ReuseTokenSource(nil, src)
+	for i := 0; i < 3; i++ {
+		tok, err := ts.Token()
+		if err != nil {
+			t.Fatalf("Token: %v", err)
+		}
+		if tok.AccessToken != "token1" {
+			t.Errorf("call %d: AccessToken = %q, want token1", i, tok.AccessToken)
+		}
+	}
+	if src.calls != 1 {
+		t.Errorf("source called %d times, want 1", src.calls)
+	}
+
+	// An expired Token is replaced lazily.
+	expired := &Token{AccessToken: "old", Expiry: time.Now().Add(-time.Minute)}
+	ts = ReuseTokenSource(expired, src)
+	if src.calls != 1 {
+		t.Errorf("ReuseTokenSource called the source")
+	}
+	tok, err := ts.Token()
+	if err != nil {
+		t.Fatalf("Token: %v", err)
+	}
+	if tok.AccessToken != "token2" {
+		t.Errorf("AccessToken = %q, want token2", tok.AccessToken)
+	}
+
+	src.err = errors.New("unavailable")
+	ts = ReuseTokenSource(nil, src)
+	if _, err := ts.Token(); err != src.err {
+		t.Errorf("Token error = %v, want %v", err, src.err)
+	}
+}
+
+func TestTransportTokenSource(t *testing.T) {
+	var refreshes int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/token":
+			refreshes++
+			w.Header().Set("Content-Type", "application/json")
+			io.WriteString(w, `{"access_token":"token2","expires_in":3600}`)
+		case "/secure":
+			if g, w := r.Header.Get("Authorization"), "Bearer token2"; g != w {
+				t.Errorf("Authorization = %q, want %q", g, w)
+			}
+		}
+	}))
+	defer server.Close()
+
+	// Two Transports share the Token of a third.
+	owner := &Transport{
+		Config: &Config{TokenURL: server.URL + "/token"},
+		Token: &Token{
+			AccessToken:  "token1",
+			RefreshToken: "refreshtoken1",
+			Expiry:       time.Now().Add(-time.Minute),
+		},
+	}
+	src := owner.Source()
+	for i := 0; i < 2; i++ {
+		transport := &Transport{TokenSource: src}
+		resp, err := transport.Client().Get(server.URL + "/secure")
+		if err != nil {
+			t.Fatalf("Get: %v", err)
+		}
+		resp.Body.Close()
+	}
+	if refreshes != 1 {
+		t.Errorf("refreshed %d times, want 1", refreshes)
+	}
+
+	transport := &Transport{TokenSource: StaticTokenSource(&Token{AccessToken: "token2"})}
+	resp, err := transport.Client().Get(server.URL + "/secure")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	resp.Body.Close()
+}